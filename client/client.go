@@ -30,6 +30,7 @@ var (
 	ErrClientQuit                = errors.New("client is closed")
 	ErrNoResult                  = errors.New("no result in JSON-RPC response")
 	ErrSubscriptionQueueOverflow = errors.New("subscription queue overflow")
+	ErrSubscriptionDisconnected  = errors.New("subscription disconnected by server for slow consumer")
 )
 
 const (
@@ -879,6 +880,31 @@ type ClientSubscription struct {
 	quit     chan struct{} // quit is closed when the subscription exits
 	errOnce  sync.Once     // ensures err is closed once
 	err      chan error
+
+	overflow Overflow // last overflow notice of server
+	mu       sync.Mutex
+}
+
+// Overflow is overflow notice sent by server when notifications of a slow
+// subscription are dropped.
+type Overflow struct {
+	Policy       string `json:"policy"`
+	Dropped      uint64 `json:"dropped"`                // total dropped items
+	Disconnected bool   `json:"disconnected,omitempty"` // subscription is dropped by server
+}
+
+// overflowNotice returns overflow notice in notification if it is one.
+func overflowNotice(result json.RawMessage) (*Overflow, bool) {
+	if !bytes.Contains(result, []byte(`"overflow"`)) {
+		return nil, false
+	}
+	var notice struct {
+		Overflow *Overflow `json:"overflow"`
+	}
+	if err := json.Unmarshal(result, &notice); err != nil || notice.Overflow == nil {
+		return nil, false
+	}
+	return notice.Overflow, true
 }
 
 func newClientSubscription(c *Client, namespace string, channel reflect.Value) *ClientSubscription {
@@ -906,6 +932,14 @@ func (sub *ClientSubscription) Err() <-chan error {
 	return sub.err
 }
 
+// Overflow returns last overflow notice of server, Dropped is zero if no
+// notification is dropped.
+func (sub *ClientSubscription) Overflow() Overflow {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.overflow
+}
+
 // Unsubscribe unsubscribes the notification and closes the error channel.
 // It can safely be called more than once.
 func (sub *ClientSubscription) Unsubscribe() {
@@ -968,6 +1002,15 @@ func (sub *ClientSubscription) forward() (err error, unsubscribeServer bool) {
 		case 0: // <-sub.quit
 			return nil, false
 		case 1: // <-sub.in
+			if o, ok := overflowNotice(recv.Interface().(json.RawMessage)); ok {
+				sub.mu.Lock()
+				sub.overflow = *o
+				sub.mu.Unlock()
+				if o.Disconnected {
+					return ErrSubscriptionDisconnected, true
+				}
+				continue
+			}
 			val, err := sub.unmarshal(recv.Interface().(json.RawMessage))
 			if err != nil {
 				return err, true
@@ -0,0 +1,214 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Gap is a period in which subscription was down, notifications sent by server
+// in the period are lost.
+type Gap struct {
+	From     time.Time // time subscription was dropped
+	To       time.Time // time subscription was established again
+	Err      error     // error which dropped subscription
+	Attempts int       // failed attempts before subscription was established
+
+	// FromSeq is sequence which events are replayed from by event
+	// resubscription, 0 if start of the gap is unknown and events are lost.
+	FromSeq uint64
+}
+
+// ResubscribeOptions is setting of Resubscribe, zero value of field is default.
+type ResubscribeOptions struct {
+	MinBackoff time.Duration // first wait after a failed attempt
+	MaxBackoff time.Duration // max wait between attempts
+	OnGap      func(Gap)     // called after subscription is established again
+}
+
+// ResubscribeFunc establishes a subscription, it is called again when the
+// subscription is dropped.
+type ResubscribeFunc func(ctx context.Context) (*ClientSubscription, error)
+
+// subscription is subscription established by Resubscription.
+type subscription interface {
+	Err() <-chan error
+	Unsubscribe()
+}
+
+// Resubscription is a subscription which is established again with backoff
+// when connection is dropped. Websocket connection is dialed again by the
+// subscribe call of client.
+type Resubscription struct {
+	fn         func(ctx context.Context) (subscription, error)
+	replayFrom func() uint64 // sequence events are replayed from, nil if not replayed
+	opts       ResubscribeOptions
+
+	quitOnce sync.Once
+	quit     chan struct{}
+	err      chan error
+}
+
+// Resubscribe calls fn to establish subscription and keeps it established until
+// Unsubscribe is called or client is closed.
+func Resubscribe(opts *ResubscribeOptions, fn ResubscribeFunc) *Resubscription {
+	return newResubscription(opts, func(ctx context.Context) (subscription, error) {
+		sub, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return sub, nil
+	}, nil)
+}
+
+func newResubscription(opts *ResubscribeOptions, fn func(ctx context.Context) (subscription, error),
+	replayFrom func() uint64) *Resubscription {
+	r := &Resubscription{
+		fn:         fn,
+		replayFrom: replayFrom,
+		quit:       make(chan struct{}),
+		err:        make(chan error, 1),
+	}
+	if opts != nil {
+		r.opts = *opts
+	}
+	if r.opts.MinBackoff <= 0 {
+		r.opts.MinBackoff = defaultMinBackoff
+	}
+	if r.opts.MaxBackoff < r.opts.MinBackoff {
+		r.opts.MaxBackoff = defaultMaxBackoff
+		if r.opts.MaxBackoff < r.opts.MinBackoff {
+			r.opts.MaxBackoff = r.opts.MinBackoff
+		}
+	}
+	go r.loop()
+	return r
+}
+
+// Err returns the subscription error channel, it is closed when the subscription
+// ends. Errors of dropped connections are reported as gaps, not by Err.
+func (r *Resubscription) Err() <-chan error {
+	return r.err
+}
+
+// Unsubscribe stops the subscription. It can safely be called more than once.
+func (r *Resubscription) Unsubscribe() {
+	r.quitOnce.Do(func() { close(r.quit) })
+}
+
+func (r *Resubscription) loop() {
+	defer close(r.err)
+
+	var (
+		subscribed bool      // subscription was established once
+		down       time.Time // time subscription was dropped
+		lastErr    error     // error which dropped subscription
+		attempts   int       // failed attempts since subscription was dropped
+		backoff    = r.opts.MinBackoff
+	)
+	for {
+		sub, err := r.subscribe()
+		if err == ErrClientQuit {
+			return
+		}
+		if err != nil {
+			attempts++
+			log.Warnf("subscribe error, attempts: %d, retry in %v: %v", attempts, backoff, err)
+			if !r.wait(backoff) {
+				return
+			}
+			backoff = r.next(backoff)
+			continue
+		}
+
+		if subscribed && !down.IsZero() {
+			gap := Gap{From: down, To: time.Now(), Err: lastErr, Attempts: attempts}
+			if r.replayFrom != nil {
+				gap.FromSeq = r.replayFrom()
+			}
+			log.Warnf("subscription is established again, gap: %v - %v", gap.From, gap.To)
+			if r.opts.OnGap != nil {
+				r.opts.OnGap(gap)
+			}
+		}
+		subscribed, down, lastErr, attempts = true, time.Time{}, nil, 0
+		backoff = r.opts.MinBackoff
+
+		select {
+		case err, ok := <-sub.Err():
+			if !ok || err == nil {
+				// unsubscribed or client is closed
+				return
+			}
+			log.Warnf("subscription is dropped: %v", err)
+			down, lastErr = time.Now(), err
+
+		case <-r.quit:
+			sub.Unsubscribe()
+			return
+		}
+	}
+}
+
+// subscribe calls fn, the call is canceled by Unsubscribe.
+func (r *Resubscription) subscribe() (subscription, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-r.quit:
+			cancel()
+		case <-done:
+		}
+	}()
+	return r.fn(ctx)
+}
+
+// wait waits for backoff with jitter, it returns false if unsubscribed.
+func (r *Resubscription) wait(backoff time.Duration) bool {
+	d := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.quit:
+		return false
+	}
+}
+
+// next returns next backoff.
+func (r *Resubscription) next(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > r.opts.MaxBackoff {
+		backoff = r.opts.MaxBackoff
+	}
+	return backoff
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// fakeSubscription is subscription which is dropped by drop.
+type fakeSubscription struct {
+	err  chan error
+	once sync.Once
+}
+
+func newFakeSubscription() *fakeSubscription {
+	return &fakeSubscription{err: make(chan error, 1)}
+}
+
+func (s *fakeSubscription) Err() <-chan error { return s.err }
+
+func (s *fakeSubscription) Unsubscribe() {
+	s.once.Do(func() { close(s.err) })
+}
+
+func (s *fakeSubscription) drop(err error) { s.err <- err }
+
+// waitClosed waits for end of resubscription.
+func waitClosed(t *testing.T, r *Resubscription) {
+	select {
+	case <-r.Err():
+	case <-time.After(5 * time.Second):
+		t.Fatal("resubscription is not closed")
+	}
+}
+
+func TestResubscribeBackoff(t *testing.T) {
+	r := &Resubscription{opts: ResubscribeOptions{MinBackoff: 10 * time.Millisecond, MaxBackoff: 40 * time.Millisecond}}
+	backoff := r.opts.MinBackoff
+	for _, want := range []time.Duration{20, 40, 40} {
+		if backoff = r.next(backoff); backoff != want*time.Millisecond {
+			t.Fatalf("next backoff: %v, want %v", backoff, want*time.Millisecond)
+		}
+	}
+
+	// attempts fail three times, waits are at least half of backoff.
+	var (
+		mu    sync.Mutex
+		times []time.Time
+	)
+	sub := newFakeSubscription()
+	established := make(chan struct{})
+	gaps := 0
+	r = newResubscription(&ResubscribeOptions{
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 40 * time.Millisecond,
+		OnGap:      func(Gap) { gaps++ },
+	}, func(ctx context.Context) (subscription, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		times = append(times, time.Now())
+		if len(times) <= 3 {
+			return nil, errors.New("refused")
+		}
+		close(established)
+		return sub, nil
+	}, nil)
+
+	select {
+	case <-established:
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription is not established")
+	}
+	r.Unsubscribe()
+	waitClosed(t, r)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, min := range []time.Duration{5, 10, 20} {
+		if d := times[i+1].Sub(times[i]); d < min*time.Millisecond {
+			t.Errorf("wait %d: %v, want at least %v", i, d, min*time.Millisecond)
+		}
+	}
+	if gaps != 0 {
+		t.Errorf("gap is reported for first subscription: %d", gaps)
+	}
+	if _, ok := <-sub.Err(); ok {
+		t.Error("subscription is not unsubscribed")
+	}
+}
+
+func TestResubscribeDefaults(t *testing.T) {
+	r := newResubscription(&ResubscribeOptions{MinBackoff: time.Minute}, func(ctx context.Context) (subscription, error) {
+		return nil, ErrClientQuit
+	}, nil)
+	waitClosed(t, r)
+	if r.opts.MaxBackoff != time.Minute {
+		t.Errorf("max backoff: %v", r.opts.MaxBackoff)
+	}
+
+	r = newResubscription(nil, func(ctx context.Context) (subscription, error) {
+		return nil, ErrClientQuit
+	}, nil)
+	waitClosed(t, r)
+	if r.opts.MinBackoff != defaultMinBackoff || r.opts.MaxBackoff != defaultMaxBackoff {
+		t.Errorf("backoff: %v - %v", r.opts.MinBackoff, r.opts.MaxBackoff)
+	}
+}
+
+func TestResubscribeGap(t *testing.T) {
+	dropErr := errors.New("connection reset")
+	subs := make(chan *fakeSubscription, 2)
+	gaps := make(chan Gap, 1)
+
+	attempts := 0
+	r := newResubscription(&ResubscribeOptions{
+		MinBackoff: time.Millisecond,
+		OnGap:      func(gap Gap) { gaps <- gap },
+	}, func(ctx context.Context) (subscription, error) {
+		attempts++
+		if attempts == 2 {
+			return nil, errors.New("refused")
+		}
+		sub := newFakeSubscription()
+		subs <- sub
+		return sub, nil
+	}, nil)
+	defer r.Unsubscribe()
+
+	(<-subs).drop(dropErr)
+	<-subs
+
+	select {
+	case gap := <-gaps:
+		if gap.Err != dropErr || gap.Attempts != 1 || gap.FromSeq != 0 || gap.To.Before(gap.From) {
+			t.Errorf("gap: %+v", gap)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("gap is not reported")
+	}
+}
+
+// eventSubscription is subscription of events established by resubscribeEvents.
+type eventSubscription struct {
+	sub  *fakeSubscription
+	in   chan cmn.Event
+	from uint64
+}
+
+func TestResubscribeEventsGap(t *testing.T) {
+	subs := make(chan *eventSubscription, 1)
+	gaps := make(chan Gap, 1)
+	subscribe := func(ctx context.Context, in chan cmn.Event, from uint64) (subscription, error) {
+		es := &eventSubscription{sub: newFakeSubscription(), in: in, from: from}
+		subs <- es
+		return es.sub, nil
+	}
+	opts := &ResubscribeOptions{MinBackoff: time.Millisecond, OnGap: func(gap Gap) { gaps <- gap }}
+	next := func(from uint64) *eventSubscription {
+		es := <-subs
+		if es.from != from {
+			t.Fatalf("subscribed from %d, want %d", es.from, from)
+		}
+		return es
+	}
+	gap := func(from uint64) {
+		select {
+		case gap := <-gaps:
+			if gap.FromSeq != from {
+				t.Fatalf("gap from seq %d, want %d", gap.FromSeq, from)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("gap is not reported")
+		}
+	}
+
+	// dropped before first event, start of gap is unknown.
+	ch := make(chan cmn.Event, 1)
+	r := resubscribeEvents(ch, 0, opts, subscribe)
+	next(0).sub.drop(errors.New("dropped"))
+	es := next(0)
+	gap(0)
+
+	// events are replayed after the last received one.
+	es.in <- cmn.Event{Seq: 5, Type: cmn.EventResult}
+	if ev := <-ch; ev.Seq != 5 {
+		t.Fatalf("event: %+v", ev)
+	}
+	es.sub.drop(errors.New("dropped"))
+	next(6)
+	gap(6)
+	r.Unsubscribe()
+	waitClosed(t, r)
+
+	// dropped before first event, replayed from the given sequence.
+	r = resubscribeEvents(ch, 3, opts, subscribe)
+	defer r.Unsubscribe()
+	next(3).sub.drop(errors.New("dropped"))
+	next(3)
+	gap(3)
+}
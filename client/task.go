@@ -0,0 +1,380 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"airman.com/airfk/pkg/common/hexutil"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+const taskNamespace = "task"
+
+// names of task subscriptions
+const (
+	resultsSubscription   = "results"
+	newTaskSubscription   = "newTask"
+	lifecycleSubscription = "lifecycle"
+)
+
+var ErrInvalidTaskInfo = errors.New("invalid task info in response")
+
+// TaskClient is a typed client of task API.
+type TaskClient struct {
+	c *Client
+}
+
+// NewTaskClient creates a task client on an existing client.
+func NewTaskClient(c *Client) *TaskClient {
+	return &TaskClient{c: c}
+}
+
+// DialTask connects to task service at the given url.
+func DialTask(rawurl string) (*TaskClient, error) {
+	return DialTaskContext(context.Background(), rawurl)
+}
+
+// DialTaskContext connects to task service at the given url with context.
+func DialTaskContext(ctx context.Context, rawurl string) (*TaskClient, error) {
+	c, err := DialContext(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return NewTaskClient(c), nil
+}
+
+// Client returns the underlying JSON-RPC client.
+func (tc *TaskClient) Client() *Client {
+	return tc.c
+}
+
+// Close closes the underlying client.
+func (tc *TaskClient) Close() {
+	tc.c.Close()
+}
+
+// Job is a task to add.
+type Job struct {
+	Name     string      // name of task
+	Type     cmn.JobType // cmd, sh or plugin
+	Extra    []byte      // command line, script or plugin name
+	Datetime time.Time   // fire time, Delay is used if it is zero
+	Delay    int         // delay in seconds
+	Retry    int         // attempts of task, default is 1
+	Webhooks []string    // urls receiving POST of result of each run
+}
+
+// jobArgs is args of task API.
+type jobArgs struct {
+	Name     *string        `json:"name"`
+	Extra    *hexutil.Bytes `json:"extra,omitempty"`
+	Type     *string        `json:"type,omitempty"`
+	UUID     uint64         `json:"uuid,omitempty"`
+	Datetime int64          `json:"datetime,omitempty"`
+	Retry    int            `json:"retry,omitempty"`
+	Interval int            `json:"interval,omitempty"`
+	Webhooks []string       `json:"webhooks,omitempty"`
+}
+
+// idArgs returns args of task API by uuid.
+func idArgs(id int64) *jobArgs {
+	name := ""
+	return &jobArgs{Name: &name, UUID: uint64(id)}
+}
+
+// eventArgs is option of subscription which replays event log.
+type eventArgs struct {
+	FromSeq uint64 `json:"fromSeq"`
+}
+
+// lifecycleArgs is option of lifecycle subscription.
+type lifecycleArgs struct {
+	Types []cmn.LifecycleType `json:"types,omitempty"`
+}
+
+// outcomes of ResultFilter
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// ResultFilter is criteria of results subscription which is evaluated by
+// server, empty field matches everything.
+type ResultFilter struct {
+	IDs     []int64     `json:"ids,omitempty"`     // uuid of tasks
+	Name    string      `json:"name,omitempty"`    // glob pattern of task name, e.g. "backup-*"
+	Type    cmn.JobType `json:"type,omitempty"`    // cmd, sh or plugin
+	Outcome string      `json:"outcome,omitempty"` // OutcomeSuccess or OutcomeFailure
+}
+
+// TaskInfo is a task returned by GetTask.
+type TaskInfo struct {
+	Job       *cmn.Job
+	State     cmn.JobState
+	StateTime int64
+	Index     int // slot of task in time wheel
+	Circle    int // circle of task in time wheel
+}
+
+// AddTask adds a task and returns its uuid.
+func (tc *TaskClient) AddTask(ctx context.Context, job *Job) (int64, error) {
+	typ, err := job.Type.MarshalText()
+	if err != nil {
+		return 0, err
+	}
+	jobType := string(typ)
+	extra := hexutil.Bytes(job.Extra)
+
+	args := &jobArgs{
+		Name:     &job.Name,
+		Extra:    &extra,
+		Type:     &jobType,
+		Retry:    job.Retry,
+		Interval: job.Delay,
+		Webhooks: job.Webhooks,
+	}
+	if !job.Datetime.IsZero() {
+		args.Datetime = job.Datetime.Unix()
+	}
+
+	var id int64
+	if err := tc.c.CallContext(ctx, &id, "task_addTask", args); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetTask returns task by uuid.
+func (tc *TaskClient) GetTask(ctx context.Context, id int64) (*TaskInfo, error) {
+	var resp struct {
+		Info      string       `json:"info"`
+		State     cmn.JobState `json:"state"`
+		StateTime int64        `json:"state_time"`
+		Index     int          `json:"index"`
+		Circle    int          `json:"circle"`
+	}
+	if err := tc.c.CallContext(ctx, &resp, "task_getTask", idArgs(id)); err != nil {
+		return nil, err
+	}
+	if resp.Info == "" {
+		return nil, ErrInvalidTaskInfo
+	}
+
+	job := new(cmn.Job)
+	if err := json.Unmarshal([]byte(resp.Info), job); err != nil {
+		return nil, err
+	}
+	return &TaskInfo{
+		Job:       job,
+		State:     resp.State,
+		StateTime: resp.StateTime,
+		Index:     resp.Index,
+		Circle:    resp.Circle,
+	}, nil
+}
+
+// GetTaskDetail returns task by uuid with its next fire time and decoded script,
+// it needs server with task_getTaskV2.
+func (tc *TaskClient) GetTaskDetail(ctx context.Context, id int64) (*cmn.TaskDetail, error) {
+	detail := new(cmn.TaskDetail)
+	if err := tc.c.CallContext(ctx, detail, "task_getTaskV2", idArgs(id)); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// CheckTask returns task is waiting in time wheel or not.
+func (tc *TaskClient) CheckTask(ctx context.Context, id int64) (bool, error) {
+	var ok bool
+	err := tc.c.CallContext(ctx, &ok, "task_checkTask", idArgs(id))
+	return ok, err
+}
+
+// DeleteTask cancels task by uuid.
+func (tc *TaskClient) DeleteTask(ctx context.Context, id int64) error {
+	return tc.c.CallContext(ctx, nil, "task_deleteTask", idArgs(id))
+}
+
+// GetResult returns last result of task by uuid.
+func (tc *TaskClient) GetResult(ctx context.Context, id int64) (*cmn.Result, error) {
+	var resp struct {
+		Info string `json:"info"`
+	}
+	if err := tc.c.CallContext(ctx, &resp, "task_getResult", idArgs(id)); err != nil {
+		return nil, err
+	}
+	if resp.Info == "" {
+		return nil, ErrInvalidTaskInfo
+	}
+
+	result := new(cmn.Result)
+	if err := json.Unmarshal([]byte(resp.Info), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetResultDetail returns last result of task by uuid with decoded output, it
+// needs server with task_getResultV2.
+func (tc *TaskClient) GetResultDetail(ctx context.Context, id int64) (*cmn.ResultDetail, error) {
+	detail := new(cmn.ResultDetail)
+	if err := tc.c.CallContext(ctx, detail, "task_getResultV2", idArgs(id)); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// SubscribeResults subscribes results of tasks, it needs websocket connection.
+func (tc *TaskClient) SubscribeResults(ctx context.Context, ch chan<- cmn.Result) (*ClientSubscription, error) {
+	return tc.c.Subscribe(ctx, taskNamespace, ch, resultsSubscription)
+}
+
+// SubscribeFilteredResults subscribes results of tasks which match filter.
+func (tc *TaskClient) SubscribeFilteredResults(ctx context.Context, ch chan<- cmn.Result, filter *ResultFilter) (*ClientSubscription, error) {
+	if filter == nil {
+		return tc.SubscribeResults(ctx, ch)
+	}
+	return tc.c.Subscribe(ctx, taskNamespace, ch, resultsSubscription, filter)
+}
+
+// SubscribeNewTasks subscribes uuid of new tasks, it needs websocket connection.
+func (tc *TaskClient) SubscribeNewTasks(ctx context.Context, ch chan<- int64) (*ClientSubscription, error) {
+	return tc.c.Subscribe(ctx, taskNamespace, ch, newTaskSubscription)
+}
+
+// SubscribeLifecycle subscribes lifecycle events of tasks and modules of the
+// given types, all types if types is empty.
+func (tc *TaskClient) SubscribeLifecycle(ctx context.Context, ch chan<- cmn.LifecycleEvent, types ...cmn.LifecycleType) (*ClientSubscription, error) {
+	return tc.c.Subscribe(ctx, taskNamespace, ch, lifecycleSubscription, &lifecycleArgs{Types: types})
+}
+
+// ResubscribeResults subscribes results of tasks like SubscribeResults, the
+// subscription is established again with backoff when connection is dropped.
+func (tc *TaskClient) ResubscribeResults(ch chan<- cmn.Result, opts *ResubscribeOptions) *Resubscription {
+	return Resubscribe(opts, func(ctx context.Context) (*ClientSubscription, error) {
+		return tc.SubscribeResults(ctx, ch)
+	})
+}
+
+// ResubscribeFilteredResults subscribes results of tasks which match filter like
+// ResubscribeResults.
+func (tc *TaskClient) ResubscribeFilteredResults(ch chan<- cmn.Result, filter *ResultFilter, opts *ResubscribeOptions) *Resubscription {
+	return Resubscribe(opts, func(ctx context.Context) (*ClientSubscription, error) {
+		return tc.SubscribeFilteredResults(ctx, ch, filter)
+	})
+}
+
+// ResubscribeNewTasks subscribes uuid of new tasks like SubscribeNewTasks, the
+// subscription is established again with backoff when connection is dropped.
+func (tc *TaskClient) ResubscribeNewTasks(ch chan<- int64, opts *ResubscribeOptions) *Resubscription {
+	return Resubscribe(opts, func(ctx context.Context) (*ClientSubscription, error) {
+		return tc.SubscribeNewTasks(ctx, ch)
+	})
+}
+
+// SubscribeResultEvents subscribes result events with sequence. Events in event
+// log from sequence fromSeq are replayed first, 0 is no replay.
+func (tc *TaskClient) SubscribeResultEvents(ctx context.Context, ch chan<- cmn.Event, fromSeq uint64) (*ClientSubscription, error) {
+	return tc.c.Subscribe(ctx, taskNamespace, ch, resultsSubscription, &eventArgs{FromSeq: fromSeq})
+}
+
+// SubscribeNewTaskEvents subscribes new task events with sequence like SubscribeResultEvents.
+func (tc *TaskClient) SubscribeNewTaskEvents(ctx context.Context, ch chan<- cmn.Event, fromSeq uint64) (*ClientSubscription, error) {
+	return tc.c.Subscribe(ctx, taskNamespace, ch, newTaskSubscription, &eventArgs{FromSeq: fromSeq})
+}
+
+// ResubscribeResultEvents subscribes result events like SubscribeResultEvents,
+// the subscription is established again from the event after the last received
+// one, so events in retention window of event log are not lost.
+func (tc *TaskClient) ResubscribeResultEvents(ch chan<- cmn.Event, fromSeq uint64, opts *ResubscribeOptions) *Resubscription {
+	return tc.resubscribeEvents(resultsSubscription, ch, fromSeq, opts)
+}
+
+// ResubscribeNewTaskEvents subscribes new task events like ResubscribeResultEvents.
+func (tc *TaskClient) ResubscribeNewTaskEvents(ch chan<- cmn.Event, fromSeq uint64, opts *ResubscribeOptions) *Resubscription {
+	return tc.resubscribeEvents(newTaskSubscription, ch, fromSeq, opts)
+}
+
+func (tc *TaskClient) resubscribeEvents(name string, ch chan<- cmn.Event, fromSeq uint64, opts *ResubscribeOptions) *Resubscription {
+	return resubscribeEvents(ch, fromSeq, opts, func(ctx context.Context, in chan cmn.Event, from uint64) (subscription, error) {
+		sub, err := tc.c.Subscribe(ctx, taskNamespace, in, name, &eventArgs{FromSeq: from})
+		if err != nil {
+			return nil, err
+		}
+		return sub, nil
+	})
+}
+
+// resubscribeEvents keeps sequence of the last received event, subscription is
+// established again from the next one. If no event is received since start from
+// sequence 0, start of gap is unknown and it is reported with FromSeq 0.
+func resubscribeEvents(ch chan<- cmn.Event, fromSeq uint64, opts *ResubscribeOptions,
+	subscribe func(ctx context.Context, in chan cmn.Event, from uint64) (subscription, error)) *Resubscription {
+	var (
+		mu       sync.Mutex
+		next     = fromSeq // sequence to replay from when subscribed again
+		replayed uint64    // sequence replayed from by the last subscription
+		in       = make(chan cmn.Event, cap(ch))
+	)
+	r := newResubscription(opts, func(ctx context.Context) (subscription, error) {
+		mu.Lock()
+		from := next
+		mu.Unlock()
+		sub, err := subscribe(ctx, in, from)
+		if err == nil {
+			mu.Lock()
+			replayed = from
+			mu.Unlock()
+		}
+		return sub, err
+	}, func() uint64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return replayed
+	})
+
+	go func() {
+		for {
+			select {
+			case ev := <-in:
+				mu.Lock()
+				if ev.Seq >= next {
+					next = ev.Seq + 1
+				}
+				mu.Unlock()
+
+				select {
+				case ch <- ev:
+				case <-r.quit:
+					return
+				case <-r.err:
+					return
+				}
+			case <-r.quit:
+				return
+			case <-r.err:
+				return
+			}
+		}
+	}()
+	return r
+}
@@ -0,0 +1,294 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"airman.com/airfk/pkg/server"
+
+	cmn "airman.com/airtask/node/common"
+	fs "airman.com/airtask/node/subscribe"
+)
+
+// TestJobArgs is args of task API received by test service.
+type TestJobArgs jobArgs
+
+// TestLifecycleArgs is args of lifecycle subscription received by test service.
+type TestLifecycleArgs lifecycleArgs
+
+var errTestNotFound = errors.New("task not found")
+
+// testTaskService serves task API in process, subscriptions send fixed
+// notifications with overflow notices of server.
+type testTaskService struct {
+	mu   sync.Mutex
+	next int64
+	jobs map[int64]*cmn.Job
+}
+
+func (s *testTaskService) AddTask(args TestJobArgs) (int64, error) {
+	var typ cmn.JobType
+	if err := typ.UnmarshalText([]byte(*args.Type)); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	s.jobs[s.next] = &cmn.Job{
+		Name:     *args.Name,
+		Type:     typ,
+		UUID:     cmn.EncodeItemID(uint64(s.next)),
+		Retry:    args.Retry,
+		Interval: args.Interval,
+		Webhooks: args.Webhooks,
+		Extra:    *args.Extra,
+	}
+	return s.next, nil
+}
+
+func (s *testTaskService) GetTask(args TestJobArgs) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[int64(args.UUID)]
+	if !ok {
+		return nil, errTestNotFound
+	}
+	info, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"info":       string(info),
+		"state":      cmn.JobStateScheduled,
+		"state_time": int64(100),
+		"index":      3,
+		"circle":     1,
+	}, nil
+}
+
+func (s *testTaskService) DeleteTask(args TestJobArgs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[int64(args.UUID)]; !ok {
+		return errTestNotFound
+	}
+	delete(s.jobs, int64(args.UUID))
+	return nil
+}
+
+// notify sends notifications of subscription in order.
+func notify(ctx context.Context, notifications ...interface{}) (*server.Subscription, error) {
+	notifier, supported := server.NotifierFromContext(ctx)
+	if !supported {
+		return &server.Subscription{}, server.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		for _, n := range notifications {
+			notifier.Notify(rpcSub.ID, n)
+		}
+	}()
+	return rpcSub, nil
+}
+
+// Results sends two results with an overflow notice between them.
+func (s *testTaskService) Results(ctx context.Context) (*server.Subscription, error) {
+	return notify(ctx,
+		&cmn.Result{ID: 1, Run: 1},
+		fs.NewOverflowNotice(fs.Overflow{Policy: fs.PolicyDropOldest, Dropped: 3}),
+		&cmn.Result{ID: 1, Run: 5},
+	)
+}
+
+// NewTask sends a uuid and disconnects subscriber.
+func (s *testTaskService) NewTask(ctx context.Context) (*server.Subscription, error) {
+	return notify(ctx,
+		int64(7),
+		fs.NewOverflowNotice(fs.Overflow{Policy: fs.PolicyDisconnect, Dropped: 9, Disconnected: true}),
+	)
+}
+
+// Lifecycle sends an event of each requested type.
+func (s *testTaskService) Lifecycle(ctx context.Context, args *TestLifecycleArgs) (*server.Subscription, error) {
+	var events []interface{}
+	for _, typ := range args.Types {
+		events = append(events, &cmn.LifecycleEvent{Type: typ, ID: 1, Name: "dev"})
+	}
+	return notify(ctx, events...)
+}
+
+// newTestTaskClient connects to test service by websocket.
+func newTestTaskClient(t *testing.T) (*TaskClient, func()) {
+	srv := server.NewServer()
+	if err := srv.RegisterName(taskNamespace, &testTaskService{jobs: make(map[int64]*cmn.Job)}); err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(server.NewWSServer([]string{"*"}, srv).Handler)
+
+	tc, err := DialTask("ws://" + strings.TrimPrefix(hs.URL, "http://"))
+	if err != nil {
+		hs.Close()
+		srv.Stop()
+		t.Fatal(err)
+	}
+	return tc, func() {
+		tc.Close()
+		hs.Close()
+		srv.Stop()
+	}
+}
+
+func TestTaskClientTasks(t *testing.T) {
+	tc, closeAll := newTestTaskClient(t)
+	defer closeAll()
+
+	ctx := context.Background()
+	job := &Job{
+		Name:     "dev",
+		Type:     cmn.JobTypeCmd,
+		Extra:    []byte("ls -l /tmp"),
+		Delay:    50,
+		Retry:    2,
+		Webhooks: []string{"http://127.0.0.1:8080/hook"},
+	}
+	id, err := tc.AddTask(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 1 {
+		t.Fatalf("id: %d, want 1", id)
+	}
+
+	info, err := tc.GetTask(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.State != cmn.JobStateScheduled || info.StateTime != 100 || info.Index != 3 || info.Circle != 1 {
+		t.Fatalf("task info: %+v", info)
+	}
+	got := info.Job
+	if got.Name != job.Name || got.Type != job.Type || got.UUID.Int64() != id || got.Retry != job.Retry ||
+		got.Interval != job.Delay || string(got.Extra) != string(job.Extra) || !reflect.DeepEqual(got.Webhooks, job.Webhooks) {
+		t.Fatalf("job: %+v", got)
+	}
+
+	if err := tc.DeleteTask(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tc.GetTask(ctx, id); err == nil || err.Error() != errTestNotFound.Error() {
+		t.Fatalf("get deleted task error: %v", err)
+	}
+	if err := tc.DeleteTask(ctx, id); err == nil || err.Error() != errTestNotFound.Error() {
+		t.Fatalf("delete deleted task error: %v", err)
+	}
+}
+
+func TestTaskClientSubscribeResults(t *testing.T) {
+	tc, closeAll := newTestTaskClient(t)
+	defer closeAll()
+
+	ch := make(chan cmn.Result)
+	sub, err := tc.SubscribeResults(context.Background(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	// overflow notice is not delivered as a result.
+	for _, run := range []uint64{1, 5} {
+		select {
+		case r := <-ch:
+			if r.ID != 1 || r.Run != run {
+				t.Fatalf("result: %+v, want run %d", r, run)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription error: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("result of run %d is not received", run)
+		}
+	}
+	if o := sub.Overflow(); o.Policy != string(fs.PolicyDropOldest) || o.Dropped != 3 || o.Disconnected {
+		t.Fatalf("overflow: %+v", o)
+	}
+}
+
+func TestTaskClientDisconnected(t *testing.T) {
+	tc, closeAll := newTestTaskClient(t)
+	defer closeAll()
+
+	ch := make(chan int64)
+	sub, err := tc.SubscribeNewTasks(context.Background(), ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	select {
+	case id := <-ch:
+		if id != 7 {
+			t.Fatalf("new task: %d, want 7", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("new task is not received")
+	}
+	select {
+	case err := <-sub.Err():
+		if err != ErrSubscriptionDisconnected {
+			t.Fatalf("subscription error: %v, want %v", err, ErrSubscriptionDisconnected)
+		}
+	case id := <-ch:
+		t.Fatalf("new task %d after disconnected", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription is not ended")
+	}
+	if o := sub.Overflow(); !o.Disconnected || o.Dropped != 9 {
+		t.Fatalf("overflow: %+v", o)
+	}
+}
+
+func TestTaskClientSubscribeLifecycle(t *testing.T) {
+	tc, closeAll := newTestTaskClient(t)
+	defer closeAll()
+
+	ch := make(chan cmn.LifecycleEvent)
+	types := []cmn.LifecycleType{cmn.LifecycleStarted, cmn.LifecycleDeleted}
+	sub, err := tc.SubscribeLifecycle(context.Background(), ch, types...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+
+	for _, typ := range types {
+		select {
+		case ev := <-ch:
+			if ev.Type != typ || ev.ID != 1 || ev.Name != "dev" {
+				t.Fatalf("event: %+v, want %s", ev, typ)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s event is not received", typ)
+		}
+	}
+}
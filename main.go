@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -29,6 +30,8 @@ import (
 
 	"airman.com/airtask/node/admin"
 	"airman.com/airtask/node/conf"
+	"airman.com/airtask/node/metrics"
+	"airman.com/airtask/node/subscribe"
 	airtask "airman.com/airtask/node/task"
 	"airman.com/airtask/node/version"
 )
@@ -38,6 +41,11 @@ var (
 	isPProf    bool
 	isVersion  bool
 
+	exportFile string // archive file to export tasks into
+	importFile string // archive file to import tasks from
+	isResults  bool   // export results
+	conflict   string // conflict policy of import
+
 	gitCommit string // commit hash
 	buildDate string // build datetime
 )
@@ -46,6 +54,10 @@ func init() {
 	flag.StringVar(&configFile, "c", "conf/website.json", "configure file")
 	flag.BoolVar(&isPProf, "p", false, "setting of pprof")
 	flag.BoolVar(&isVersion, "v", false, "version information")
+	flag.StringVar(&exportFile, "export", "", "export tasks into archive file and exit")
+	flag.StringVar(&importFile, "import", "", "import tasks from archive file and exit")
+	flag.BoolVar(&isResults, "results", false, "export results with tasks")
+	flag.StringVar(&conflict, "conflict", airtask.ConflictSkip, "conflict policy of import: skip, overwrite or renew")
 }
 
 func startPProf(address string) {
@@ -57,6 +69,88 @@ func startPProf(address string) {
 	}()
 }
 
+// startOps serves metrics in Prometheus text format, liveness and readiness
+// of manager on the address.
+func startOps(address string, manager *airtask.Manager) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", airtask.HealthHandler(manager.Live))
+	mux.Handle("/readyz", airtask.HealthHandler(manager.Ready))
+
+	log.Infof("Starting metrics server, %s", fmt.Sprintf("http://%s/metrics", address))
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Errorf("Failure in running metrics server, %v", err)
+		}
+	}()
+}
+
+// taskOptions returns options of task manager by config.
+func taskOptions(dataDir string, config *conf.Config) *airtask.Options {
+	opts := airtask.DefaultOptions(dataDir)
+	if config.Engine != "" {
+		opts.Engine = config.Engine
+	}
+	opts.ExecTimeout = time.Duration(config.ExecTimeout) * time.Second
+	opts.Retention.MaxAge = time.Duration(config.Retention.MaxAge) * time.Second
+	opts.Retention.MaxRuns = config.Retention.MaxRuns
+	opts.Retention.MaxSize = config.Retention.MaxSize
+	if config.Retention.EventMaxAge > 0 {
+		opts.Retention.EventMaxAge = time.Duration(config.Retention.EventMaxAge) * time.Second
+	}
+	if config.Retention.DeliveryMaxAge > 0 {
+		opts.Retention.DeliveryMaxAge = time.Duration(config.Retention.DeliveryMaxAge) * time.Second
+	}
+	if config.Retention.Interval > 0 {
+		opts.Retention.Interval = time.Duration(config.Retention.Interval) * time.Second
+	}
+	opts.Subscribe.BufferSize = config.Subscribe.Buffer
+	opts.Subscribe.Policy = subscribe.Policy(config.Subscribe.Overflow)
+	opts.Webhook.URLs = config.Webhook.URLs
+	opts.Webhook.Secret = config.Webhook.Secret
+	if config.Webhook.MaxAttempts > 0 {
+		opts.Webhook.MaxAttempts = config.Webhook.MaxAttempts
+	}
+	if config.Webhook.Timeout > 0 {
+		opts.Webhook.Timeout = time.Duration(config.Webhook.Timeout) * time.Second
+	}
+	if config.Health.MaxTickAge > 0 {
+		opts.Health.MaxTickAge = time.Duration(config.Health.MaxTickAge) * time.Second
+	}
+	if config.Health.MaxLockWait > 0 {
+		opts.Health.MaxLockWait = time.Duration(config.Health.MaxLockWait) * time.Second
+	}
+	return opts
+}
+
+// runArchive exports or imports tasks with manager in process, node should be stopped.
+func runArchive(config *conf.Config) error {
+	opts := taskOptions(config.DataDir, config)
+	opts.NodeID = config.Id
+
+	manager, err := airtask.New(opts)
+	if err != nil {
+		return err
+	}
+	if err := manager.Start(); err != nil {
+		return err
+	}
+	defer manager.Stop()
+
+	var stats *airtask.ArchiveStats
+	if exportFile != "" {
+		stats, err = manager.ExportFile(exportFile, isResults)
+	} else {
+		stats, err = manager.ImportFile(importFile, conflict)
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("archive tasks: %d, results: %d, skipped: %d, renewed: %d",
+		stats.Tasks, stats.Results, stats.Skipped, stats.Renewed)
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -90,6 +184,14 @@ func main() {
 	log.SetLevel(log.Level(config.Level))
 	log.SetReportCaller(true)
 
+	// export or import mode
+	if exportFile != "" || importFile != "" {
+		if err := runArchive(config); err != nil {
+			log.Fatalf("archive error:%v", err)
+		}
+		os.Exit(0)
+	}
+
 	stack, err := admin.NewNode(config)
 	if err != nil {
 		log.Fatalf("new node error:%v", err)
@@ -97,8 +199,11 @@ func main() {
 
 	log.Info("step1: new node is okay")
 
+	var manager *airtask.Manager
 	constructor := func(ctx *service.ServiceContext) (service.Service, error) {
-		return airtask.NewManager(stack), nil
+		manager = airtask.NewManagerWithOptions(stack, taskOptions(stack.DataDir(), config))
+		stack.SetAuditor(manager)
+		return manager, nil
 	}
 	if err := stack.Register(constructor); err != nil {
 		log.Fatalf("Failed to register service: %v", err)
@@ -111,6 +216,10 @@ func main() {
 
 	log.Info("step2: node is running now")
 
+	if config.MetricsAddr != "" {
+		startOps(config.MetricsAddr, manager)
+	}
+
 	for {
 		select {
 		case s := <-c:
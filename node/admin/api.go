@@ -16,6 +16,7 @@
 package admin
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -38,10 +39,16 @@ func NewPrivateAdminAPI(node Backend) *PrivateAdminAPI {
 }
 
 // StartWS starts the websocket RPC API server.
-func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *string, apis *string) (bool, error) {
+func (api *PrivateAdminAPI) StartWS(ctx context.Context, host *string, port *int, allowedOrigins *string, apis *string) (ok bool, err error) {
 	api.mu.Lock()
 	defer api.mu.Unlock()
 
+	defer func() {
+		api.audit(ctx, "admin_startWS", map[string]interface{}{
+			"host": host, "port": port, "origins": allowedOrigins, "apis": apis,
+		}, err)
+	}()
+
 	if api.node.WSHandle() != nil {
 		return false, fmt.Errorf("WebSocket RPC already running on %s", api.node.WSEndpoint())
 	}
@@ -78,10 +85,14 @@ func (api *PrivateAdminAPI) StartWS(host *string, port *int, allowedOrigins *str
 }
 
 // StopWS terminates an already running websocket RPC API endpoint.
-func (api *PrivateAdminAPI) StopWS() (bool, error) {
+func (api *PrivateAdminAPI) StopWS(ctx context.Context) (ok bool, err error) {
 	api.mu.Lock()
 	defer api.mu.Unlock()
 
+	defer func() {
+		api.audit(ctx, "admin_stopWS", nil, err)
+	}()
+
 	if api.node.WSHandle() == nil {
 		return false, fmt.Errorf("WebSocket RPC not running")
 	}
@@ -89,6 +100,13 @@ func (api *PrivateAdminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// audit records call into audit log of node.
+func (api *PrivateAdminAPI) audit(ctx context.Context, action string, params interface{}, err error) {
+	if auditor := api.node.Auditor(); auditor != nil {
+		auditor.Audit(ctx, action, params, err)
+	}
+}
+
 // PublicAdminAPI is the collection of administrative API methods exposed over
 // both secure and unsecure RPC channels.
 type PublicAdminAPI struct {
@@ -16,6 +16,8 @@
 package admin
 
 import (
+	"context"
+
 	"airman.com/airfk/pkg/server"
 	"airman.com/airfk/pkg/service"
 	"airman.com/airfk/pkg/types"
@@ -38,4 +40,10 @@ type Backend interface {
 	Config() interface{}
 	RpcAPIs() []types.API
 	Services() []service.Service
+	Auditor() Auditor
+}
+
+// Auditor records mutating API calls into audit log.
+type Auditor interface {
+	Audit(ctx context.Context, action string, params interface{}, err error)
 }
@@ -0,0 +1,130 @@
+// Copyright 2014 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"airman.com/airfk/pkg/server"
+	"airman.com/airfk/pkg/types"
+	"golang.org/x/net/websocket"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// startHTTPEndpoint starts HTTP RPC server of apis in modules, requests carry
+// their caller for audit log.
+func startHTTPEndpoint(endpoint string, apis []types.API, modules []string, cors []string) (net.Listener, *server.Server, error) {
+	handler, err := newRPCServer(apis, modules)
+	if err != nil {
+		return nil, nil, err
+	}
+	listener, err := serve(endpoint, server.NewHTTPServer(cors, handler))
+	if err != nil {
+		handler.Stop()
+		return nil, nil, err
+	}
+	return listener, handler, nil
+}
+
+// startWSEndpoint starts websocket RPC server of apis in modules, requests of a
+// connection carry caller of the connection for audit log.
+func startWSEndpoint(endpoint string, apis []types.API, modules []string, wsOrigins []string) (net.Listener, *server.Server, error) {
+	handler, err := newRPCServer(apis, modules)
+	if err != nil {
+		return nil, nil, err
+	}
+	listener, err := serve(endpoint, &http.Server{Handler: wsHandler(handler, wsOrigins)})
+	if err != nil {
+		handler.Stop()
+		return nil, nil, err
+	}
+	return listener, handler, nil
+}
+
+// newRPCServer registers apis in modules, public apis if modules is empty.
+func newRPCServer(apis []types.API, modules []string) (*server.Server, error) {
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+
+	handler := server.NewServer()
+	for _, api := range apis {
+		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				handler.Stop()
+				return nil, err
+			}
+		}
+	}
+	return handler, nil
+}
+
+// serve listens on endpoint and serves requests by srv.
+func serve(endpoint string, srv *http.Server) (net.Listener, error) {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	srv.Handler = callerHandler(srv.Handler)
+	go srv.Serve(listener)
+	return listener, nil
+}
+
+// callerHandler sets remote address and user agent of request into its context.
+func callerHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := cmn.WithCaller(r.Context(), r.RemoteAddr, r.UserAgent())
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// wsHandler serves websocket connections by RPC server. Connections of websocket
+// server of airfk are served with background context, so they are served here
+// with context carrying remote address and user agent of upgrade request.
+func wsHandler(handler *server.Server, origins []string) http.Handler {
+	return websocket.Server{
+		Handshake: wsHandshake(origins),
+		Handler: func(conn *websocket.Conn) {
+			r := conn.Request()
+			ctx := cmn.WithCaller(context.Background(), r.RemoteAddr, r.UserAgent())
+			handler.ServeCodecContext(ctx, server.NewJSONCodec(conn),
+				server.OptionMethodInvocation|server.OptionSubscriptions)
+		},
+	}
+}
+
+// wsHandshake accepts connections from allowed origins, "*" allows all origins.
+// Connection without origin is not from browser and it is accepted.
+func wsHandshake(origins []string) func(*websocket.Config, *http.Request) error {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.ToLower(origin)] = true
+	}
+	return func(config *websocket.Config, r *http.Request) error {
+		origin := strings.ToLower(r.Header.Get("Origin"))
+		if origin == "" || allowed["*"] || allowed[origin] {
+			return nil
+		}
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+}
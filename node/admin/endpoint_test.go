@@ -0,0 +1,101 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package admin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"airman.com/airfk/pkg/types"
+	"golang.org/x/net/websocket"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// callerAPI returns caller of request.
+type callerAPI struct{}
+
+func (api *callerAPI) Caller(ctx context.Context) string {
+	remote, agent := cmn.CallerFromContext(ctx)
+	return remote + " " + agent
+}
+
+func TestWSEndpointCaller(t *testing.T) {
+	apis := []types.API{{Namespace: "test", Version: "1.0", Service: &callerAPI{}, Public: true}}
+	listener, handler, err := startWSEndpoint("127.0.0.1:0", apis, nil, []string{"*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Stop()
+	defer listener.Close()
+
+	config, err := websocket.NewConfig("ws://"+listener.Addr().String(), "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("User-Agent", "airtask-test")
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// calls of a connection have the caller of the connection.
+	for id := 1; id <= 2; id++ {
+		req := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": "test_caller", "params": []interface{}{}}
+		if err := websocket.JSON.Send(conn, req); err != nil {
+			t.Fatal(err)
+		}
+		var resp struct {
+			ID     int    `json:"id"`
+			Result string `json:"result"`
+		}
+		if err := websocket.JSON.Receive(conn, &resp); err != nil {
+			t.Fatal(err)
+		}
+		remote, agent := "", ""
+		if fields := strings.Fields(resp.Result); len(fields) == 2 {
+			remote, agent = fields[0], fields[1]
+		}
+		if resp.ID != id || !strings.HasPrefix(remote, "127.0.0.1:") || agent != "airtask-test" {
+			t.Fatalf("caller of call %d: %+v", id, resp)
+		}
+	}
+}
+
+func TestWSHandshake(t *testing.T) {
+	for _, c := range []struct {
+		origins []string
+		origin  string
+		ok      bool
+	}{
+		{nil, "", true},
+		{nil, "http://evil.com", false},
+		{[]string{"*"}, "http://evil.com", true},
+		{[]string{"http://Localhost:8080"}, "http://localhost:8080", true},
+		{[]string{"http://localhost:8080"}, "http://localhost:9090", false},
+	} {
+		req := &http.Request{Header: http.Header{}}
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		if err := wsHandshake(c.origins)(&websocket.Config{}, req); (err == nil) != c.ok {
+			t.Errorf("origins %v, origin %q: %v", c.origins, c.origin, err)
+		}
+	}
+}
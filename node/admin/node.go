@@ -84,6 +84,7 @@ type Node struct {
 	wsEndpoint   string
 	wsListener   net.Listener   // Websocket RPC listener socket to server API requests
 	wsHandler    *server.Server // Websocket RPC request handler to process the API requests
+	auditor      Auditor        // Audit log of mutating API calls
 
 	lock sync.RWMutex
 	stop chan struct{} // Channel to wait for termination notifications
@@ -225,7 +226,7 @@ func (n *Node) startHTTP(c *conf.Config, apis []types.API) error {
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := server.StartHTTPEndpoint(endpoint, apis, c.HTTPModules, c.HTTPOrigins)
+	listener, handler, err := startHTTPEndpoint(endpoint, apis, c.HTTPModules, c.HTTPOrigins)
 	if err != nil {
 		return err
 	}
@@ -258,7 +259,7 @@ func (n *Node) StartWS(c *conf.Config, apis []types.API) error {
 	// Short circuit if the WS endpoint isn't being exposed
 	endpoint := fmt.Sprintf("%s:%d", c.WSHost, c.WSPort)
 
-	listener, handler, err := server.StartWSEndpoint(endpoint, apis, c.WSModules, c.WSOrigins)
+	listener, handler, err := startWSEndpoint(endpoint, apis, c.WSModules, c.WSOrigins)
 	if err != nil {
 		return err
 	}
@@ -361,6 +362,22 @@ func (n *Node) NodeID() string {
 	return n.conf.Id
 }
 
+// SetAuditor sets audit log of mutating admin API calls.
+func (n *Node) SetAuditor(auditor Auditor) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.auditor = auditor
+}
+
+// Auditor returns audit log of node, it is nil if not set.
+func (n *Node) Auditor() Auditor {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return n.auditor
+}
+
 // HTTPEndpoint retrieves the current HTTP endpoint used by the protocol stack.
 func (n *Node) HTTPEndpoint() string {
 	return n.httpEndpoint
@@ -0,0 +1,41 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package common
+
+import "context"
+
+// contextKey is type of keys of values in context set by this package.
+type contextKey int
+
+const (
+	remoteKey contextKey = iota // remote address of RPC request
+	agentKey                    // user agent of RPC request
+	jobKey                      // task run by in-process handler
+)
+
+// WithCaller returns context carrying remote address and user agent of RPC request.
+func WithCaller(ctx context.Context, remote, agent string) context.Context {
+	ctx = context.WithValue(ctx, remoteKey, remote)
+	return context.WithValue(ctx, agentKey, agent)
+}
+
+// CallerFromContext returns remote address and user agent set by WithCaller,
+// they are empty if the call is not from RPC.
+func CallerFromContext(ctx context.Context) (remote, agent string) {
+	remote, _ = ctx.Value(remoteKey).(string)
+	agent, _ = ctx.Value(agentKey).(string)
+	return remote, agent
+}
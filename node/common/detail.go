@@ -0,0 +1,100 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package common
+
+import (
+	"unicode/utf8"
+
+	"airman.com/airfk/pkg/common/hexutil"
+)
+
+// TaskDetail is a task with fields computed by server, it is returned by v2 API.
+type TaskDetail struct {
+	UUID      int64         `json:"uuid"`
+	Name      string        `json:"name"`
+	Type      JobType       `json:"type"`
+	Retry     int           `json:"retry"`
+	Interval  int           `json:"interval"`
+	AddTime   int64         `json:"add_time"`
+	LimitTime int64         `json:"limit_time"`
+	State     JobState      `json:"state"`
+	StateTime int64         `json:"state_time"`
+	Webhooks  []string      `json:"webhooks,omitempty"`
+	Extra     hexutil.Bytes `json:"extra"`
+	Script    string        `json:"script,omitempty"`    // extra as text if it is valid UTF-8
+	NextFire  int64         `json:"next_fire,omitempty"` // fire time of scheduled task in unix seconds
+	Scheduled bool          `json:"scheduled"`           // task is waiting in time wheel
+	Index     int           `json:"index"`               // slot of task in time wheel
+	Circle    int           `json:"circle"`              // circle of task in time wheel
+}
+
+// ResultDetail is a result with fields computed by server, it is returned by v2 API.
+type ResultDetail struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name,omitempty"`
+	Type      JobType       `json:"type,omitempty"`
+	Run       uint64        `json:"run"`
+	BeginTime int64         `json:"begin_time"`
+	EndTime   int64         `json:"end_time"`
+	Duration  int64         `json:"duration"` // seconds of run
+	Succeeded bool          `json:"succeeded"`
+	Error     string        `json:"error"`
+	Output    hexutil.Bytes `json:"output"`
+	Text      string        `json:"text,omitempty"` // output as text if it is valid UTF-8
+}
+
+// NewTaskDetail returns detail of job, fields of time wheel are not set.
+func NewTaskDetail(job *Job) *TaskDetail {
+	return &TaskDetail{
+		UUID:      job.UUID.Int64(),
+		Name:      job.Name,
+		Type:      job.Type,
+		Retry:     job.Retry,
+		Interval:  job.Interval,
+		AddTime:   job.AddTime,
+		LimitTime: job.LimitTime,
+		State:     job.State,
+		StateTime: job.StateTime,
+		Webhooks:  job.Webhooks,
+		Extra:     job.Extra,
+		Script:    text(job.Extra),
+	}
+}
+
+// NewResultDetail returns detail of result.
+func NewResultDetail(r *Result) *ResultDetail {
+	return &ResultDetail{
+		ID:        r.ID,
+		Name:      r.Name,
+		Type:      r.Type,
+		Run:       r.Run,
+		BeginTime: r.BeginTime,
+		EndTime:   r.EndTime,
+		Duration:  r.EndTime - r.BeginTime,
+		Succeeded: r.Succeeded(),
+		Error:     r.ErrorMsg,
+		Output:    r.Extra,
+		Text:      text(r.Extra),
+	}
+}
+
+// text returns b as string if it is valid UTF-8, otherwise empty string.
+func text(b []byte) string {
+	if !utf8.Valid(b) {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// checkFields checks fields of encoded object.
+func checkFields(t *testing.T, data []byte, want map[string]string) {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if got := string(fields[k]); got != v {
+			t.Errorf("%s: %s, want %s", k, got, v)
+		}
+	}
+}
+
+func TestTaskDetailJSON(t *testing.T) {
+	job := &Job{
+		Name:      "dev",
+		Type:      JobTypeFile,
+		UUID:      EncodeItemID(362450735830401024),
+		Retry:     2,
+		Interval:  50,
+		AddTime:   1561217877,
+		LimitTime: 1561218877,
+		State:     JobStatePaused,
+		StateTime: 1561217900,
+		Extra:     []byte("ls -l /tmp"),
+	}
+	detail := NewTaskDetail(job)
+	detail.NextFire, detail.Index, detail.Circle = 1561217927, 98, 1
+
+	data, err := json.Marshal(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, data, map[string]string{
+		"uuid":       "362450735830401024",
+		"type":       `"sh"`,
+		"state":      `"paused"`,
+		"state_time": "1561217900",
+		"add_time":   "1561217877",
+		"limit_time": "1561218877",
+		"next_fire":  "1561217927",
+		"extra":      `"0x6c73202d6c202f746d70"`,
+		"script":     `"ls -l /tmp"`,
+		"scheduled":  "false",
+	})
+
+	decoded := new(TaskDetail)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, detail) {
+		t.Fatalf("decoded %#v, want %#v", decoded, detail)
+	}
+
+	// binary extra has no script.
+	job.Extra = []byte{0xff, 0x00}
+	data, err = json.Marshal(NewTaskDetail(job))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, data, map[string]string{"extra": `"0xff00"`, "script": ""})
+}
+
+func TestResultDetailJSON(t *testing.T) {
+	r := &Result{
+		ID:        1,
+		Name:      "dev",
+		Type:      JobTypeCmd,
+		Run:       3,
+		BeginTime: 1561217877,
+		EndTime:   1561217880,
+		ErrorMsg:  "exit status 1",
+		Extra:     []byte("no such file\n"),
+	}
+	detail := NewResultDetail(r)
+	data, err := json.Marshal(detail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, data, map[string]string{
+		"begin_time": "1561217877",
+		"end_time":   "1561217880",
+		"duration":   "3",
+		"succeeded":  "false",
+		"error":      `"exit status 1"`,
+		"output":     `"0x6e6f20737563682066696c650a"`,
+		"text":       `"no such file\n"`,
+	})
+
+	decoded := new(ResultDetail)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, detail) {
+		t.Fatalf("decoded %#v, want %#v", decoded, detail)
+	}
+
+	// succeeded result with binary output has no text.
+	r.ErrorMsg, r.Extra = ToMsg(nil), []byte{0xfe}
+	data, err = json.Marshal(NewResultDetail(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"text"`) {
+		t.Fatalf("text of binary output: %s", data)
+	}
+	checkFields(t, data, map[string]string{"succeeded": "true", "output": `"0xfe"`})
+}
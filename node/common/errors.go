@@ -17,17 +17,112 @@ package common
 
 import (
 	"errors"
+	"fmt"
+)
+
+// JSON-RPC error codes of task API, they are stable and never reused.
+const (
+	CodeNotFound    = -32001 // task, result or module does not exist
+	CodeInvalid     = -32002 // argument fails validation
+	CodeConflict    = -32003 // operation conflicts with state of task or module
+	CodeUnavailable = -32004 // task service is not running or storage is not available
 )
 
 var (
 	// parameter is invalid
-	ErrInvalidParameter = errors.New("invalid parameter")
+	ErrInvalidParameter = NewInvalidError("", "invalid parameter")
+
+	ErrInvalidDatetime = NewInvalidError("datetime", "invalid datetime")
+
+	ErrInvalidPluginName = NewInvalidError("extra", "invalid plugin name")
+
+	ErrDuplicateModule = NewConflictError("duplicate module", nil)
 
-	ErrInvalidDatetime = errors.New("invalid datetime")
+	ErrTaskInterrupted = errors.New("task interrupted by restart")
 
-	ErrInvalidPluginName = errors.New("invalid plugin name")
+	ErrTaskExpired = errors.New("task expired")
+
+	ErrTaskCancelled = errors.New("task cancelled while running")
+
+	ErrInvalidWebhook = NewInvalidError("webhooks", "invalid webhook url")
 )
 
+// Error is an error of task API with JSON-RPC error code and data, server
+// sends Code as code of error response and Data as its data.
+type Error struct {
+	Code    int
+	Message string
+	Data    *ErrorDetail
+}
+
+// ErrorDetail is data of error, fields not related to the error are empty.
+type ErrorDetail struct {
+	Kind   string `json:"kind,omitempty"`   // task, result or module
+	ID     int64  `json:"id,omitempty"`     // uuid of task
+	Module string `json:"module,omitempty"` // id of module, name@version
+	Field  string `json:"field,omitempty"`  // name of invalid argument
+	State  string `json:"state,omitempty"`  // current state of task
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns JSON-RPC error code.
+func (e *Error) ErrorCode() int {
+	return e.Code
+}
+
+// ErrorData returns JSON-RPC error data.
+func (e *Error) ErrorData() interface{} {
+	if e.Data == nil {
+		return nil
+	}
+	return e.Data
+}
+
+// NewNotFoundError returns error of task or result which does not exist.
+func NewNotFoundError(kind string, id int64) *Error {
+	return &Error{
+		Code:    CodeNotFound,
+		Message: fmt.Sprintf("%s %d not found", kind, id),
+		Data:    &ErrorDetail{Kind: kind, ID: id},
+	}
+}
+
+// NewInvalidError returns error of invalid argument field.
+func NewInvalidError(field, msg string) *Error {
+	e := &Error{Code: CodeInvalid, Message: msg}
+	if field != "" {
+		e.Data = &ErrorDetail{Field: field}
+	}
+	return e
+}
+
+// NewConflictError returns error of operation conflicting with state.
+func NewConflictError(msg string, detail *ErrorDetail) *Error {
+	return &Error{Code: CodeConflict, Message: msg, Data: detail}
+}
+
+// NewUnavailableError returns error of service which is not available.
+func NewUnavailableError(msg string) *Error {
+	return &Error{Code: CodeUnavailable, Message: msg}
+}
+
+// ErrorCodeOf returns JSON-RPC error code of err, it is 0 if err has no code.
+// It works with errors of server and errors returned by client.
+func ErrorCodeOf(err error) int {
+	if e, ok := err.(interface{ ErrorCode() int }); ok {
+		return e.ErrorCode()
+	}
+	return 0
+}
+
+// IsNotFound returns true if err is a not found error.
+func IsNotFound(err error) bool {
+	return ErrorCodeOf(err) == CodeNotFound
+}
+
 func ToMsg(e error) string {
 	if e == nil {
 		return "success"
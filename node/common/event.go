@@ -0,0 +1,57 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package common
+
+// types of event
+const (
+	EventResult  = "result"  // result of a run of task
+	EventNewTask = "newTask" // task is added
+)
+
+// Event is an event of task in event log, events are ordered by sequence.
+type Event struct {
+	Seq    uint64  `json:"seq"`
+	Time   int64   `json:"time"`
+	Type   string  `json:"type"`
+	ID     int64   `json:"id"` // uuid of task
+	Result *Result `json:"result,omitempty"`
+}
+
+// LifecycleType is kind of lifecycle event of task or module.
+type LifecycleType string
+
+// types of lifecycle event
+const (
+	LifecycleStarted       LifecycleType = "started"       // attempt of task is started
+	LifecycleRetrying      LifecycleType = "retrying"      // attempt of task failed and it is retried
+	LifecycleTimedOut      LifecycleType = "timedOut"      // attempt of task is timed out
+	LifecycleCancelled     LifecycleType = "cancelled"     // task is cancelled by delete
+	LifecycleExpired       LifecycleType = "expired"       // task is fired after its limit time
+	LifecycleDeleted       LifecycleType = "deleted"       // record of task is removed by delete or compactor
+	LifecycleModuleAdded   LifecycleType = "moduleAdded"   // module is loaded or registered
+	LifecycleModuleRemoved LifecycleType = "moduleRemoved" // module is dropped or unregistered
+)
+
+// LifecycleEvent is a change of task or module which is not a result.
+type LifecycleEvent struct {
+	Type    LifecycleType `json:"type"`
+	Time    int64         `json:"time"`
+	ID      int64         `json:"id,omitempty"`      // uuid of task
+	Name    string        `json:"name,omitempty"`    // name of task
+	Module  string        `json:"module,omitempty"`  // id of module, name@version
+	Attempt int           `json:"attempt,omitempty"` // attempt of run, from 1
+	Error   string        `json:"error,omitempty"`
+}
@@ -21,6 +21,9 @@ func (j Job) MarshalJSON() ([]byte, error) {
 		Interval  int           `json:"interval" gencodec:"required"`
 		AddTime   int64         `json:"add_time"`
 		LimitTime int64         `json:"limit_time"`
+		State     JobState      `json:"state"`
+		StateTime int64         `json:"state_time"`
+		Webhooks  []string      `json:"webhooks,omitempty"`
 		Extra     hexutil.Bytes `json:"extra"`
 	}
 	var enc Job
@@ -31,6 +34,9 @@ func (j Job) MarshalJSON() ([]byte, error) {
 	enc.Interval = j.Interval
 	enc.AddTime = j.AddTime
 	enc.LimitTime = j.LimitTime
+	enc.State = j.State
+	enc.StateTime = j.StateTime
+	enc.Webhooks = j.Webhooks
 	enc.Extra = j.Extra
 	return json.Marshal(&enc)
 }
@@ -45,6 +51,9 @@ func (j *Job) UnmarshalJSON(input []byte) error {
 		Interval  *int           `json:"interval" gencodec:"required"`
 		AddTime   *int64         `json:"add_time"`
 		LimitTime *int64         `json:"limit_time"`
+		State     *JobState      `json:"state"`
+		StateTime *int64         `json:"state_time"`
+		Webhooks  []string       `json:"webhooks,omitempty"`
 		Extra     *hexutil.Bytes `json:"extra"`
 	}
 	var dec Job
@@ -75,6 +84,15 @@ func (j *Job) UnmarshalJSON(input []byte) error {
 	if dec.LimitTime != nil {
 		j.LimitTime = *dec.LimitTime
 	}
+	if dec.State != nil {
+		j.State = *dec.State
+	}
+	if dec.StateTime != nil {
+		j.StateTime = *dec.StateTime
+	}
+	if dec.Webhooks != nil {
+		j.Webhooks = dec.Webhooks
+	}
 	if dec.Extra != nil {
 		j.Extra = *dec.Extra
 	}
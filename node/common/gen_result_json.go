@@ -15,6 +15,9 @@ var _ = (*resultMarshaling)(nil)
 func (r Result) MarshalJSON() ([]byte, error) {
 	type Result struct {
 		ID        int64         `json:"id"          gencodec:"required"`
+		Name      string        `json:"name,omitempty"`
+		Type      JobType       `json:"type,omitempty"`
+		Run       uint64        `json:"run"`
 		BeginTime int64         `json:"begin_time"  gencodec:"required"`
 		EndTime   int64         `json:"end_time"    gencodec:"required"`
 		ErrorMsg  string        `json:"error"       gencodec:"required"`
@@ -22,6 +25,9 @@ func (r Result) MarshalJSON() ([]byte, error) {
 	}
 	var enc Result
 	enc.ID = r.ID
+	enc.Name = r.Name
+	enc.Type = r.Type
+	enc.Run = r.Run
 	enc.BeginTime = r.BeginTime
 	enc.EndTime = r.EndTime
 	enc.ErrorMsg = r.ErrorMsg
@@ -33,6 +39,9 @@ func (r Result) MarshalJSON() ([]byte, error) {
 func (r *Result) UnmarshalJSON(input []byte) error {
 	type Result struct {
 		ID        *int64         `json:"id"          gencodec:"required"`
+		Name      *string        `json:"name,omitempty"`
+		Type      *JobType       `json:"type,omitempty"`
+		Run       *uint64        `json:"run"`
 		BeginTime *int64         `json:"begin_time"  gencodec:"required"`
 		EndTime   *int64         `json:"end_time"    gencodec:"required"`
 		ErrorMsg  *string        `json:"error"       gencodec:"required"`
@@ -46,6 +55,15 @@ func (r *Result) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'id' for Result")
 	}
 	r.ID = *dec.ID
+	if dec.Name != nil {
+		r.Name = *dec.Name
+	}
+	if dec.Type != nil {
+		r.Type = *dec.Type
+	}
+	if dec.Run != nil {
+		r.Run = *dec.Run
+	}
 	if dec.BeginTime == nil {
 		return errors.New("missing required field 'begin_time' for Result")
 	}
@@ -70,6 +70,6 @@ func (n ItemID) MarshalText() ([]byte, error) {
 }
 
 // UnmarshalText implements encoding.TextUnmarshaler.
-func (n ItemID) UnmarshalText(input []byte) error {
+func (n *ItemID) UnmarshalText(input []byte) error {
 	return hexutil.UnmarshalFixedText("ItemID", input, n[:])
 }
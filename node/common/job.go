@@ -16,6 +16,7 @@
 package common
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -26,14 +27,17 @@ import (
 
 // Job is task job.
 type Job struct {
-	Name      string  `json:"name"     gencodec:"required"`
-	Type      JobType `json:"type"`
-	UUID      ItemID  `json:"uuid"`
-	Retry     int     `json:"retry"    gencodec:"required"`
-	Interval  int     `json:"interval" gencodec:"required"`
-	AddTime   int64   `json:"add_time"`
-	LimitTime int64   `json:"limit_time"`
-	Extra     []byte  `json:"extra"`
+	Name      string   `json:"name"     gencodec:"required"`
+	Type      JobType  `json:"type"`
+	UUID      ItemID   `json:"uuid"`
+	Retry     int      `json:"retry"    gencodec:"required"`
+	Interval  int      `json:"interval" gencodec:"required"`
+	AddTime   int64    `json:"add_time"`
+	LimitTime int64    `json:"limit_time"`
+	State     JobState `json:"state"`
+	StateTime int64    `json:"state_time"`
+	Webhooks  []string `json:"webhooks,omitempty"`
+	Extra     []byte   `json:"extra"`
 }
 
 type jobMarshaling struct {
@@ -68,6 +72,18 @@ func (j *Job) ID() int64 {
 }
 
 func (j *Job) String() string {
-	return fmt.Sprintf("id:%d,name:%s,delay:%v,retry:%d,create:%d,limit:%d",
-		j.UUID, j.Name, j.Interval, j.Retry, j.AddTime, j.LimitTime)
+	return fmt.Sprintf("id:%d,name:%s,delay:%v,retry:%d,create:%d,limit:%d,state:%s",
+		j.UUID, j.Name, j.Interval, j.Retry, j.AddTime, j.LimitTime, j.State)
+}
+
+// WithJob returns context carrying task which is run by in-process handler.
+func WithJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobKey, job)
+}
+
+// JobFromContext returns task set by WithJob, handler reads its uuid, name and
+// extra by it.
+func JobFromContext(ctx context.Context) (*Job, bool) {
+	job, ok := ctx.Value(jobKey).(*Job)
+	return job, ok
 }
@@ -0,0 +1,122 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// JobState is state of job in its lifecycle.
+type JobState uint8
+
+const (
+	JobStateUnknown   JobState = iota
+	JobStateScheduled          // waiting in time wheel
+	JobStateRunning            // fired and running
+	JobStateRetrying           // last attempt failed, waiting for next attempt
+	JobStateSucceeded          // finished without error
+	JobStateFailed             // finished with error after all attempts
+	JobStateCancelled          // deleted before it is finished
+	JobStateExpired            // fired after its limit time, not run
+	JobStatePaused             // removed from time wheel until it is resumed
+)
+
+var (
+	ErrInvalidJobState   = errors.New("invalid job state")
+	ErrInvalidTransition = errors.New("invalid job state transition")
+)
+
+// JobStates is all known states.
+var JobStates = []JobState{
+	JobStateScheduled, JobStateRunning, JobStateRetrying, JobStateSucceeded,
+	JobStateFailed, JobStateCancelled, JobStateExpired, JobStatePaused,
+}
+
+var jobStateNames = map[JobState]string{
+	JobStateUnknown:   "unknown",
+	JobStateScheduled: "scheduled",
+	JobStateRunning:   "running",
+	JobStateRetrying:  "retrying",
+	JobStateSucceeded: "succeeded",
+	JobStateFailed:    "failed",
+	JobStateCancelled: "cancelled",
+	JobStateExpired:   "expired",
+	JobStatePaused:    "paused",
+}
+
+// transitions is allowed next states of each state.
+var transitions = map[JobState][]JobState{
+	JobStateUnknown:   {JobStateScheduled},
+	JobStateScheduled: {JobStateRunning, JobStateCancelled, JobStateExpired, JobStatePaused},
+	JobStatePaused:    {JobStateScheduled, JobStateCancelled, JobStateExpired},
+	JobStateRunning:   {JobStateSucceeded, JobStateFailed, JobStateRetrying, JobStateCancelled},
+	JobStateRetrying:  {JobStateRunning, JobStateFailed, JobStateCancelled},
+}
+
+// UnmarshalText parses the given text into a JobState.
+func (js *JobState) UnmarshalText(data []byte) error {
+	input := strings.TrimSpace(string(data))
+
+	for s, name := range jobStateNames {
+		if name == input {
+			*js = s
+			return nil
+		}
+	}
+	return ErrInvalidJobState
+}
+
+func (js JobState) String() string {
+	if name, ok := jobStateNames[js]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown state : %d", js)
+}
+
+func (js JobState) MarshalText() ([]byte, error) {
+	if name, ok := jobStateNames[js]; ok {
+		return []byte(name), nil
+	}
+	return nil, ErrInvalidJobState
+}
+
+// IsTerminal returns true if job never changes state again.
+func (js JobState) IsTerminal() bool {
+	return len(transitions[js]) == 0
+}
+
+// CanTransit returns true if job can move from js to the given state.
+func (js JobState) CanTransit(to JobState) bool {
+	for _, s := range transitions[js] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transit moves job to the given state at time now in unix seconds.
+func (j *Job) Transit(to JobState, now int64) error {
+	if !j.State.CanTransit(to) {
+		return NewConflictError(fmt.Sprintf("%v: %s -> %s", ErrInvalidTransition, j.State, to),
+			&ErrorDetail{Kind: "task", ID: j.UUID.Int64(), State: j.State.String()})
+	}
+	j.State = to
+	j.StateTime = now
+	return nil
+}
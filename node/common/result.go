@@ -24,11 +24,14 @@ import (
 
 // Result is result of execute task job.
 type Result struct {
-	ID        int64  `json:"id"          gencodec:"required"`
-	BeginTime int64  `json:"begin_time"  gencodec:"required"`
-	EndTime   int64  `json:"end_time"    gencodec:"required"`
-	ErrorMsg  string `json:"error"       gencodec:"required"`
-	Extra     []byte `json:"output"`
+	ID        int64   `json:"id"          gencodec:"required"`
+	Name      string  `json:"name,omitempty"`
+	Type      JobType `json:"type,omitempty"`
+	Run       uint64  `json:"run"`
+	BeginTime int64   `json:"begin_time"  gencodec:"required"`
+	EndTime   int64   `json:"end_time"    gencodec:"required"`
+	ErrorMsg  string  `json:"error"       gencodec:"required"`
+	Extra     []byte  `json:"output"`
 }
 
 type resultMarshaling struct {
@@ -53,6 +56,11 @@ func NewResultWithEnd(id int64, begin, end int64, msg string, extra []byte) *Res
 	}
 }
 
+// Succeeded returns whether run of task is successful.
+func (r *Result) Succeeded() bool {
+	return r.ErrorMsg == ToMsg(nil)
+}
+
 func (r *Result) Set(end int64, msg string, extra []byte) {
 	if r != nil {
 		r.EndTime = end
@@ -63,8 +71,8 @@ func (r *Result) Set(end int64, msg string, extra []byte) {
 
 func (r *Result) String() string {
 	if r != nil {
-		return fmt.Sprintf("ID:%d, begin:%d, end:%v, error:%s, extra:%v",
-			r.ID, r.BeginTime, r.EndTime, r.ErrorMsg, r.Extra)
+		return fmt.Sprintf("ID:%d, run:%d, begin:%d, end:%v, error:%s, extra:%v",
+			r.ID, r.Run, r.BeginTime, r.EndTime, r.ErrorMsg, r.Extra)
 	}
 	return ""
 }
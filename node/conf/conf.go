@@ -47,6 +47,45 @@ type Config struct {
 	WSPort      int            `toml:",omitempty" json:"ws_port"`
 	WSOrigins   []string       `toml:",omitempty" json:"ws_origins"`
 	WSModules   []string       `toml:",omitempty" json:"ws_modules"`
+	Engine      string         `toml:",omitempty" json:"engine"`
+	ExecTimeout int            `toml:",omitempty" json:"exec_timeout"` // max seconds of a run of task, no limit if 0
+	Retention   Retention      `toml:",omitempty" json:"retention"`
+	Subscribe   Subscribe      `toml:",omitempty" json:"subscribe"`
+	Webhook     Webhook        `toml:",omitempty" json:"webhook"`
+	MetricsAddr string         `toml:",omitempty" json:"metrics_addr"` // address of metrics and health endpoints, disabled if empty
+	Health      Health         `toml:",omitempty" json:"health"`
+}
+
+// Retention is setting of retention of tasks, results and scripts,
+// zero value of limit means no limit.
+type Retention struct {
+	MaxAge         int   `toml:",omitempty" json:"max_age"`          // max age in seconds
+	MaxRuns        int   `toml:",omitempty" json:"max_runs"`         // max runs of results per task
+	MaxSize        int64 `toml:",omitempty" json:"max_size"`         // max total size of results in bytes
+	EventMaxAge    int   `toml:",omitempty" json:"event_max_age"`    // max age of events in seconds, default is one day
+	DeliveryMaxAge int   `toml:",omitempty" json:"delivery_max_age"` // max age of finished deliveries in seconds, default is seven days
+	Interval       int   `toml:",omitempty" json:"interval"`         // compaction interval in seconds
+}
+
+// Subscribe is setting of buffers of subscribers, overflow is dropOldest
+// (default), dropNewest or disconnect.
+type Subscribe struct {
+	Buffer   int    `toml:",omitempty" json:"buffer"`   // max buffered events per subscriber
+	Overflow string `toml:",omitempty" json:"overflow"` // policy when buffer is full
+}
+
+// Health is setting of liveness and readiness checks.
+type Health struct {
+	MaxTickAge  int `toml:",omitempty" json:"max_tick_age"`  // seconds since last tick when ready, default is 30
+	MaxLockWait int `toml:",omitempty" json:"max_lock_wait"` // seconds update loop waits for lock when live, default is 300
+}
+
+// Webhook is setting of webhooks receiving results of all tasks.
+type Webhook struct {
+	URLs        []string `toml:",omitempty" json:"urls"`
+	Secret      string   `toml:",omitempty" json:"secret"`       // key of HMAC-SHA256 signature
+	MaxAttempts int      `toml:",omitempty" json:"max_attempts"` // default is 10
+	Timeout     int      `toml:",omitempty" json:"timeout"`      // request timeout in seconds, default is 10
 }
 
 // DefaultConfig contains reasonable default settings.
@@ -63,6 +102,7 @@ var DefaultConfig = &Config{
 	WSOrigins:   []string{"*"},
 	WSModules:   []string{"admin", "task"},
 	WSPort:      DefaultWSPort,
+	Engine:      "leveldb",
 }
 
 func NewConfig(name, version, dataDir, host string, level int) *Config {
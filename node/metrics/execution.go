@@ -0,0 +1,114 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// outcomes of execution
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// ExecutionBuckets is upper bounds of execution duration histogram in seconds.
+var ExecutionBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+
+type execKey struct {
+	typ     string
+	outcome string
+}
+
+// labels returns type and outcome labels in Prometheus text format.
+func (k execKey) labels() string {
+	return fmt.Sprintf(`type="%s",outcome="%s"`, labelValue(k.typ), labelValue(k.outcome))
+}
+
+type execHistogram struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // cumulative count by ExecutionBuckets
+}
+
+var executions = struct {
+	sync.Mutex
+	m map[execKey]*execHistogram
+}{m: make(map[execKey]*execHistogram)}
+
+// ObserveExecution records a run of task by job type and outcome.
+func ObserveExecution(typ, outcome string, d time.Duration) {
+	executions.Lock()
+	defer executions.Unlock()
+
+	key := execKey{typ: typ, outcome: outcome}
+	h, ok := executions.m[key]
+	if !ok {
+		h = &execHistogram{buckets: make([]uint64, len(ExecutionBuckets))}
+		executions.m[key] = h
+	}
+	seconds := d.Seconds()
+	h.count++
+	h.sum += seconds
+	for i, le := range ExecutionBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+}
+
+// writeExecutions writes execution counters and duration histograms in
+// Prometheus text format.
+func writeExecutions(w io.Writer) {
+	executions.Lock()
+	defer executions.Unlock()
+
+	keys := make([]execKey, 0, len(executions.m))
+	for key := range executions.m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].typ != keys[j].typ {
+			return keys[i].typ < keys[j].typ
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+
+	total := promName("task/executions") + "_total"
+	fmt.Fprintf(w, "# HELP %s Runs of tasks by job type and outcome.\n", total)
+	fmt.Fprintf(w, "# TYPE %s counter\n", total)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s} %d\n", total, key.labels(), executions.m[key].count)
+	}
+
+	duration := promName("task/execution/duration/seconds")
+	fmt.Fprintf(w, "# HELP %s Duration of runs of tasks by job type and outcome.\n", duration)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", duration)
+	for _, key := range keys {
+		h := executions.m[key]
+		labels := key.labels()
+		for i, le := range ExecutionBuckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%g\"} %d\n", duration, labels, le, h.buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", duration, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", duration, labels, h.sum)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", duration, labels, h.count)
+	}
+}
@@ -8,4 +8,18 @@ var (
 	TaskAddMeter     = metrics.NewRegisteredMeter("task/add", nil)
 	TaskExecuteMeter = metrics.NewRegisteredMeter("task/execute", nil)
 	TaskExecuteTimer = metrics.NewRegisteredTimer("task/useTime", nil)
+
+	CompactTaskCounter   = metrics.NewRegisteredCounter("task/compact/tasks", nil)
+	CompactResultCounter = metrics.NewRegisteredCounter("task/compact/results", nil)
+	CompactScriptCounter = metrics.NewRegisteredCounter("task/compact/scripts", nil)
+	CompactEventCounter  = metrics.NewRegisteredCounter("task/compact/events", nil)
+	CompactHookCounter   = metrics.NewRegisteredCounter("task/compact/deliveries", nil)
+	CompactBytesCounter  = metrics.NewRegisteredCounter("task/compact/bytes", nil)
+
+	SubscribeDropCounter       = metrics.NewRegisteredCounter("subscribe/dropped", nil)
+	SubscribeDisconnectCounter = metrics.NewRegisteredCounter("subscribe/disconnected", nil)
+
+	WebhookDeliveredCounter = metrics.NewRegisteredCounter("webhook/delivered", nil)
+	WebhookRetryCounter     = metrics.NewRegisteredCounter("webhook/retried", nil)
+	WebhookFailedCounter    = metrics.NewRegisteredCounter("webhook/failed", nil)
 )
@@ -0,0 +1,127 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// namespace is prefix of exported metric names.
+const namespace = "airtask"
+
+// quantiles of timers and histograms exported as summary.
+var quantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// labelEscaper escapes label value, only backslash, double quote and line feed
+// are escaped in Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labelValue returns escaped label value.
+func labelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// promName converts go-metrics name like task/compact/tasks to Prometheus name.
+func promName(name string) string {
+	var b strings.Builder
+	b.WriteString(namespace)
+	b.WriteByte('_')
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// WritePrometheus writes metrics of registry and execution histograms in
+// Prometheus text format. Meters are exported as counters, timers and
+// histograms as summaries, durations are in seconds.
+func WritePrometheus(w io.Writer, r metrics.Registry) {
+	all := make(map[string]interface{})
+	r.Each(func(name string, i interface{}) {
+		all[name] = i
+	})
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pn := promName(name)
+		switch m := all[name].(type) {
+		case metrics.Counter:
+			writeHelp(w, pn+"_total", name)
+			fmt.Fprintf(w, "# TYPE %s_total counter\n%s_total %d\n", pn, pn, m.Count())
+		case metrics.Gauge:
+			writeHelp(w, pn, name)
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %d\n", pn, pn, m.Value())
+		case metrics.GaugeFloat64:
+			writeHelp(w, pn, name)
+			fmt.Fprintf(w, "# TYPE %s gauge\n%s %g\n", pn, pn, m.Value())
+		case metrics.Meter:
+			s := m.Snapshot()
+			writeHelp(w, pn+"_total", name)
+			fmt.Fprintf(w, "# TYPE %s_total counter\n%s_total %d\n", pn, pn, s.Count())
+			writeHelp(w, pn+"_rate1m", name)
+			fmt.Fprintf(w, "# TYPE %s_rate1m gauge\n%s_rate1m %g\n", pn, pn, s.Rate1())
+		case metrics.Timer:
+			s := m.Snapshot()
+			writeHelp(w, pn+"_seconds", name)
+			writeSummary(w, pn+"_seconds", s.Percentiles(quantiles), float64(s.Sum())/float64(time.Second), s.Count(), float64(time.Second))
+		case metrics.Histogram:
+			s := m.Snapshot()
+			writeHelp(w, pn, name)
+			writeSummary(w, pn, s.Percentiles(quantiles), float64(s.Sum()), s.Count(), 1)
+		}
+	}
+	writeExecutions(w)
+}
+
+// writeHelp writes help line of exported metric with its go-metrics name.
+func writeHelp(w io.Writer, name, metric string) {
+	fmt.Fprintf(w, "# HELP %s Metric %s.\n", name, metric)
+}
+
+// writeSummary writes quantiles, sum and count, quantile values are divided by unit.
+func writeSummary(w io.Writer, name string, ps []float64, sum float64, count int64, unit float64) {
+	fmt.Fprintf(w, "# TYPE %s summary\n", name)
+	for i, q := range quantiles {
+		fmt.Fprintf(w, "%s{quantile=\"%g\"} %g\n", name, q, ps[i]/unit)
+	}
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, sum, name, count)
+}
+
+// Handler returns handler of /metrics which exports default registry.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		WritePrometheus(&buf, metrics.DefaultRegistry)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
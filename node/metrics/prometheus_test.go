@@ -0,0 +1,106 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+func hasLines(t *testing.T, text string, lines ...string) {
+	have := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		have[line] = true
+	}
+	for _, line := range lines {
+		if !have[line] {
+			t.Errorf("missing line %q in:\n%s", line, text)
+		}
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	r := metrics.NewRegistry()
+	metrics.NewRegisteredCounter("test/count", r).Inc(3)
+	metrics.NewRegisteredGauge("test/gauge", r).Update(7)
+	metrics.NewRegisteredMeter("test/meter", r).Mark(2)
+	metrics.NewRegisteredTimer("test/timer", r).Update(1500 * time.Millisecond)
+
+	var buf bytes.Buffer
+	WritePrometheus(&buf, r)
+	hasLines(t, buf.String(),
+		"# HELP airtask_test_count_total Metric test/count.",
+		"# TYPE airtask_test_count_total counter",
+		"airtask_test_count_total 3",
+		"# HELP airtask_test_gauge Metric test/gauge.",
+		"# TYPE airtask_test_gauge gauge",
+		"airtask_test_gauge 7",
+		"# TYPE airtask_test_meter_total counter",
+		"airtask_test_meter_total 2",
+		"# TYPE airtask_test_meter_rate1m gauge",
+		"# HELP airtask_test_timer_seconds Metric test/timer.",
+		"# TYPE airtask_test_timer_seconds summary",
+		`airtask_test_timer_seconds{quantile="0.5"} 1.5`,
+		"airtask_test_timer_seconds_sum 1.5",
+		"airtask_test_timer_seconds_count 1",
+	)
+}
+
+func TestWriteExecutions(t *testing.T) {
+	typ := "test\"\\\n"
+	ObserveExecution(typ, OutcomeFailure, 300*time.Millisecond)
+	ObserveExecution(typ, OutcomeFailure, 2*time.Second)
+
+	var buf bytes.Buffer
+	writeExecutions(&buf)
+	labels := `type="test\"\\\n",outcome="failure"`
+	hasLines(t, buf.String(),
+		"# HELP airtask_task_executions_total Runs of tasks by job type and outcome.",
+		"# TYPE airtask_task_executions_total counter",
+		"airtask_task_executions_total{"+labels+"} 2",
+		"# TYPE airtask_task_execution_duration_seconds histogram",
+		"airtask_task_execution_duration_seconds_bucket{"+labels+`,le="0.1"} 0`,
+		"airtask_task_execution_duration_seconds_bucket{"+labels+`,le="0.5"} 1`,
+		"airtask_task_execution_duration_seconds_bucket{"+labels+`,le="1"} 1`,
+		"airtask_task_execution_duration_seconds_bucket{"+labels+`,le="5"} 2`,
+		"airtask_task_execution_duration_seconds_bucket{"+labels+`,le="+Inf"} 2`,
+		"airtask_task_execution_duration_seconds_sum{"+labels+"} 2.3",
+		"airtask_task_execution_duration_seconds_count{"+labels+"} 2",
+	)
+}
+
+func TestHandler(t *testing.T) {
+	c := metrics.NewRegisteredCounter("test/handler", nil)
+	defer metrics.Unregister("test/handler")
+	c.Inc(1)
+	ObserveExecution("handler", OutcomeSuccess, 50*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("content type: %s", ct)
+	}
+	hasLines(t, w.Body.String(),
+		"# TYPE airtask_test_handler_total counter",
+		"airtask_test_handler_total 1",
+		`airtask_task_execution_duration_seconds_bucket{type="handler",outcome="success",le="0.1"} 1`,
+	)
+}
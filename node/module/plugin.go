@@ -52,7 +52,16 @@ func (m *Module) String() string {
 	return fmt.Sprintf("name:%s,version:%s", m.name, m.version)
 }
 
+// IsBuiltin returns true if module is registered in process, not loaded from so file.
+func (m *Module) IsBuiltin() bool {
+	return m.file == "" && m.mainHandle != nil
+}
+
 func (m *Module) Execute(ctx context.Context) error {
+	if m.IsBuiltin() {
+		return m.executeFuncs(ctx)
+	}
+
 	p, err := plugin.Open(m.file)
 	if err != nil {
 		return err
@@ -76,18 +85,30 @@ func (m *Module) Execute(ctx context.Context) error {
 	return nil
 }
 
+// executeFuncs runs the in-process handles.
+func (m *Module) executeFuncs(ctx context.Context) error {
+	if err := m.mainHandle(ctx); err != nil {
+		if m.errHandle != nil {
+			m.errHandle(ctx, err)
+		}
+		return err
+	}
+	return nil
+}
+
 func (m *Module) ExecuteWithRetry(ctx context.Context, retryTimes int) error {
 	times := retryTimes
 	if retryTimes == 0 {
 		times = 1
 	}
 
+	var err error
 	for i := 0; i < times; i++ {
-		if err := m.Execute(ctx); err != nil {
-			log.Error("index: %d execute module: %s error: %v", i, m, err)
+		if err = m.Execute(ctx); err != nil {
+			log.Errorf("index: %d execute module: %s error: %v", i, m, err)
 		} else {
 			break
 		}
 	}
-	return nil
+	return err
 }
@@ -0,0 +1,102 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// engines of backend
+const (
+	EngineLevelDB = "leveldb"
+	EngineBolt    = "bolt"
+	EngineMemory  = "memory"
+)
+
+var (
+	// key is not found in backend
+	ErrNotFound = errors.New("not found")
+)
+
+// Reader reads key value from backend.
+type Reader interface {
+	// Has retrieves if a key is present.
+	Has(key []byte) (bool, error)
+
+	// Get retrieves the given key, ErrNotFound is returned if key is not present.
+	Get(key []byte) ([]byte, error)
+
+	// NewIterator creates an iterator over keys with the given prefix, in key order.
+	NewIterator(prefix []byte) Iterator
+}
+
+// Backend is key value storage engine of store.
+type Backend interface {
+	Reader
+
+	// Put inserts the given value.
+	Put(key []byte, value []byte) error
+
+	// Delete removes the given key.
+	Delete(key []byte) error
+
+	// NewBatch creates a write batch which is written atomically.
+	NewBatch() BatchWriter
+
+	// NewSnapshot creates a consistent read only view of backend.
+	NewSnapshot() (Snapshot, error)
+
+	// Close closes backend.
+	Close() error
+}
+
+// BatchWriter is write batch of backend.
+type BatchWriter interface {
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	Write() error
+}
+
+// Iterator iterates keys of backend. It must be released after use.
+type Iterator interface {
+	First() bool
+	Seek(key []byte) bool
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// Snapshot is read only view of backend at a point in time. It must be released after use.
+type Snapshot interface {
+	Reader
+	Release()
+}
+
+// Open opens backend of the given engine, file is ignored by memory engine.
+func Open(engine string, file string) (Backend, error) {
+	switch engine {
+	case "", EngineLevelDB:
+		return NewLevelDB(file)
+	case EngineBolt:
+		return NewBolt(file)
+	case EngineMemory:
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("unknown storage engine %q", engine)
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"errors"
+)
+
+var (
+	ErrDifferentDatabase = errors.New("store is not on database of batch")
+)
+
+// Batch is a write batch of stores on the same database, it is written atomically.
+type Batch struct {
+	db    Backend
+	batch BatchWriter
+}
+
+// NewBatch creates a write batch on database of store.
+func (t *Store) NewBatch() *Batch {
+	return &Batch{
+		db:    t.db,
+		batch: t.db.NewBatch(),
+	}
+}
+
+// Put inserts the given value of store into the batch.
+func (b *Batch) Put(t *Store, key []byte, value []byte) error {
+	if t.db != b.db {
+		return ErrDifferentDatabase
+	}
+	return b.batch.Put(t.key(key), value)
+}
+
+// Delete removes the given key of store in the batch.
+func (b *Batch) Delete(t *Store, key []byte) error {
+	if t.db != b.db {
+		return ErrDifferentDatabase
+	}
+	return b.batch.Delete(t.key(key))
+}
+
+// Write writes the batch into database.
+func (b *Batch) Write() error {
+	return b.batch.Write()
+}
@@ -0,0 +1,300 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"bytes"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// boltChunkSize is number of keys read by iterator in a transaction.
+	boltChunkSize = 1024
+
+	// boltMmapSize is initial mmap size, writer is blocked by open snapshot
+	// when data file is remapped, so it is large enough to remap rarely.
+	boltMmapSize = 256 << 20
+)
+
+var (
+	// boltBucket is the bucket of all keys.
+	boltBucket = []byte("airtask")
+)
+
+// Bolt is backend of bbolt.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// NewBolt opens bbolt backend of the given file.
+func NewBolt(file string) (*Bolt, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second, InitialMmapSize: boltMmapSize})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Has retrieves if a key is present.
+func (b *Bolt) Has(key []byte) (bool, error) {
+	var ok bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		ok = tx.Bucket(boltBucket).Get(key) != nil
+		return nil
+	})
+	return ok, err
+}
+
+// Get retrieves the given key.
+func (b *Bolt) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(boltBucket).Get(key)
+		if v == nil {
+			return ErrNotFound
+		}
+		value = append([]byte(nil), v...)
+		return nil
+	})
+	return value, err
+}
+
+// Put inserts the given value.
+func (b *Bolt) Put(key []byte, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, boltValue(value))
+	})
+}
+
+// Delete removes the given key.
+func (b *Bolt) Delete(key []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+// NewBatch creates a write batch.
+func (b *Bolt) NewBatch() BatchWriter {
+	return &boltBatch{db: b.db}
+}
+
+// NewIterator creates an iterator over keys with the given prefix.
+func (b *Bolt) NewIterator(prefix []byte) Iterator {
+	return &boltIterator{
+		view:   b.db.View,
+		prefix: append([]byte(nil), prefix...),
+		index:  -1,
+	}
+}
+
+// NewSnapshot creates a snapshot by read only transaction.
+func (b *Bolt) NewSnapshot() (Snapshot, error) {
+	tx, err := b.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return &boltSnapshot{tx: tx}, nil
+}
+
+// Close closes bbolt.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// boltValue returns not nil value, bbolt does not store nil value.
+func boltValue(value []byte) []byte {
+	if value == nil {
+		return []byte{}
+	}
+	return value
+}
+
+type boltOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// boltBatch is write batch of bbolt, it is written in one transaction.
+type boltBatch struct {
+	db  *bolt.DB
+	ops []boltOp
+}
+
+// Put inserts the given value into batch.
+func (b *boltBatch) Put(key []byte, value []byte) error {
+	b.ops = append(b.ops, boltOp{key: append([]byte(nil), key...), value: append([]byte{}, value...)})
+	return nil
+}
+
+// Delete removes the given key in batch.
+func (b *boltBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, boltOp{key: append([]byte(nil), key...), delete: true})
+	return nil
+}
+
+// Write writes batch into bbolt.
+func (b *boltBatch) Write() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		for _, op := range b.ops {
+			var err error
+			if op.delete {
+				err = bucket.Delete(op.key)
+			} else {
+				err = bucket.Put(op.key, op.value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// boltSnapshot is snapshot of bbolt.
+type boltSnapshot struct {
+	tx *bolt.Tx
+}
+
+// Has retrieves if a key is present.
+func (s *boltSnapshot) Has(key []byte) (bool, error) {
+	return s.tx.Bucket(boltBucket).Get(key) != nil, nil
+}
+
+// Get retrieves the given key.
+func (s *boltSnapshot) Get(key []byte) ([]byte, error) {
+	v := s.tx.Bucket(boltBucket).Get(key)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// NewIterator creates an iterator over keys with the given prefix.
+func (s *boltSnapshot) NewIterator(prefix []byte) Iterator {
+	view := func(fn func(tx *bolt.Tx) error) error {
+		return fn(s.tx)
+	}
+	return &boltIterator{view: view, prefix: append([]byte(nil), prefix...), index: -1}
+}
+
+// Release releases snapshot.
+func (s *boltSnapshot) Release() {
+	s.tx.Rollback()
+}
+
+// boltIterator reads keys by chunk, every chunk is read in its own transaction,
+// so no transaction is held between calls.
+type boltIterator struct {
+	view   func(fn func(tx *bolt.Tx) error) error
+	prefix []byte
+	keys   [][]byte
+	values [][]byte
+	index  int
+	done   bool // no more keys after the current chunk
+	err    error
+}
+
+// load reads a chunk of keys from the given key, the key is skipped if exclusive.
+func (it *boltIterator) load(from []byte, exclusive bool) bool {
+	it.keys, it.values, it.index = nil, nil, 0
+	it.err = it.view(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		k, v := c.Seek(from)
+		if exclusive && k != nil && bytes.Equal(k, from) {
+			k, v = c.Next()
+		}
+		for ; k != nil && bytes.HasPrefix(k, it.prefix); k, v = c.Next() {
+			if len(it.keys) == boltChunkSize {
+				return nil
+			}
+			it.keys = append(it.keys, append([]byte(nil), k...))
+			it.values = append(it.values, append([]byte(nil), v...))
+		}
+		it.done = true
+		return nil
+	})
+	return it.err == nil && len(it.keys) > 0
+}
+
+// First moves iterator to the first key.
+func (it *boltIterator) First() bool {
+	it.done = false
+	return it.load(it.prefix, false)
+}
+
+// Seek moves iterator to the first key which is greater than or equal to the given key.
+func (it *boltIterator) Seek(key []byte) bool {
+	it.done = false
+	if bytes.Compare(key, it.prefix) < 0 {
+		key = it.prefix
+	}
+	return it.load(key, false)
+}
+
+// Next moves iterator to the next key.
+func (it *boltIterator) Next() bool {
+	if it.index < 0 {
+		return it.First()
+	}
+	if it.index+1 < len(it.keys) {
+		it.index++
+		return true
+	}
+	if it.done || len(it.keys) == 0 {
+		it.index = len(it.keys)
+		return false
+	}
+	return it.load(it.keys[len(it.keys)-1], true)
+}
+
+// Key returns key of current position.
+func (it *boltIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+// Value returns value of current position.
+func (it *boltIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+// Error returns error of iterator.
+func (it *boltIterator) Error() error {
+	return it.err
+}
+
+// Release releases iterator.
+func (it *boltIterator) Release() {
+	it.keys = nil
+	it.values = nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"airman.com/airfk/pkg/leveldb"
+	ldb "github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDB is backend of leveldb.
+type LevelDB struct {
+	db *leveldb.LevelDB
+}
+
+// NewLevelDB opens leveldb backend in the given directory.
+func NewLevelDB(file string) (*LevelDB, error) {
+	db, err := leveldb.NewLDBDatabase(file, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDB{db: db}, nil
+}
+
+// Has retrieves if a key is present.
+func (l *LevelDB) Has(key []byte) (bool, error) {
+	return l.db.Has(key)
+}
+
+// Get retrieves the given key.
+func (l *LevelDB) Get(key []byte) ([]byte, error) {
+	value, err := l.db.Get(key)
+	if err == lerrors.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Put inserts the given value.
+func (l *LevelDB) Put(key []byte, value []byte) error {
+	return l.db.Put(key, value)
+}
+
+// Delete removes the given key.
+func (l *LevelDB) Delete(key []byte) error {
+	return l.db.Delete(key)
+}
+
+// NewBatch creates a write batch.
+func (l *LevelDB) NewBatch() BatchWriter {
+	return l.db.NewBatch()
+}
+
+// NewIterator creates an iterator over keys with the given prefix.
+func (l *LevelDB) NewIterator(prefix []byte) Iterator {
+	return l.db.NewIteratorWithPrefix(prefix)
+}
+
+// NewSnapshot creates a snapshot of leveldb.
+func (l *LevelDB) NewSnapshot() (Snapshot, error) {
+	snap, err := l.db.LDB().GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelSnapshot{snap: snap}, nil
+}
+
+// Close closes leveldb.
+func (l *LevelDB) Close() error {
+	l.db.Close()
+	return nil
+}
+
+// levelSnapshot is snapshot of leveldb.
+type levelSnapshot struct {
+	snap *ldb.Snapshot
+}
+
+// Has retrieves if a key is present.
+func (s *levelSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(key, nil)
+}
+
+// Get retrieves the given key.
+func (s *levelSnapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.snap.Get(key, nil)
+	if err == lerrors.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// NewIterator creates an iterator over keys with the given prefix.
+func (s *levelSnapshot) NewIterator(prefix []byte) Iterator {
+	return s.snap.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+// Release releases snapshot.
+func (s *levelSnapshot) Release() {
+	s.snap.Release()
+}
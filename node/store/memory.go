@@ -0,0 +1,221 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory is in-memory backend, it is used in tests.
+type Memory struct {
+	db   map[string][]byte
+	lock sync.RWMutex
+}
+
+// NewMemory returns in-memory backend.
+func NewMemory() *Memory {
+	return &Memory{
+		db: make(map[string][]byte),
+	}
+}
+
+// Has retrieves if a key is present.
+func (m *Memory) Has(key []byte) (bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	_, ok := m.db[string(key)]
+	return ok, nil
+}
+
+// Get retrieves the given key.
+func (m *Memory) Get(key []byte) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if value, ok := m.db[string(key)]; ok {
+		return append([]byte(nil), value...), nil
+	}
+	return nil, ErrNotFound
+}
+
+// Put inserts the given value.
+func (m *Memory) Put(key []byte, value []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.db[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete removes the given key.
+func (m *Memory) Delete(key []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.db, string(key))
+	return nil
+}
+
+// NewBatch creates a write batch.
+func (m *Memory) NewBatch() BatchWriter {
+	return &memoryBatch{db: m}
+}
+
+// NewIterator creates an iterator over keys with the given prefix.
+func (m *Memory) NewIterator(prefix []byte) Iterator {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return newMemoryIterator(m.db, prefix)
+}
+
+// NewSnapshot creates a copy of memory backend.
+func (m *Memory) NewSnapshot() (Snapshot, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	snap := NewMemory()
+	for k, v := range m.db {
+		snap.db[k] = v
+	}
+	return &memorySnapshot{snap}, nil
+}
+
+// Close closes memory backend.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// memorySnapshot is snapshot of memory backend.
+type memorySnapshot struct {
+	*Memory
+}
+
+// Release releases snapshot.
+func (s *memorySnapshot) Release() {}
+
+type memoryOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// memoryBatch is write batch of memory backend.
+type memoryBatch struct {
+	db  *Memory
+	ops []memoryOp
+}
+
+// Put inserts the given value into batch.
+func (b *memoryBatch) Put(key []byte, value []byte) error {
+	b.ops = append(b.ops, memoryOp{key: append([]byte(nil), key...), value: append([]byte(nil), value...)})
+	return nil
+}
+
+// Delete removes the given key in batch.
+func (b *memoryBatch) Delete(key []byte) error {
+	b.ops = append(b.ops, memoryOp{key: append([]byte(nil), key...), delete: true})
+	return nil
+}
+
+// Write writes batch into memory backend.
+func (b *memoryBatch) Write() error {
+	b.db.lock.Lock()
+	defer b.db.lock.Unlock()
+
+	for _, op := range b.ops {
+		if op.delete {
+			delete(b.db.db, string(op.key))
+		} else {
+			b.db.db[string(op.key)] = op.value
+		}
+	}
+	return nil
+}
+
+// memoryIterator iterates sorted copy of keys.
+type memoryIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func newMemoryIterator(db map[string][]byte, prefix []byte) *memoryIterator {
+	it := &memoryIterator{index: -1}
+	for k := range db {
+		if strings.HasPrefix(k, string(prefix)) {
+			it.keys = append(it.keys, k)
+		}
+	}
+	sort.Strings(it.keys)
+	for _, k := range it.keys {
+		it.values = append(it.values, db[k])
+	}
+	return it
+}
+
+// First moves iterator to the first key.
+func (it *memoryIterator) First() bool {
+	it.index = 0
+	return it.index < len(it.keys)
+}
+
+// Seek moves iterator to the first key which is greater than or equal to the given key.
+func (it *memoryIterator) Seek(key []byte) bool {
+	it.index = sort.Search(len(it.keys), func(i int) bool {
+		return bytes.Compare([]byte(it.keys[i]), key) >= 0
+	})
+	return it.index < len(it.keys)
+}
+
+// Next moves iterator to the next key.
+func (it *memoryIterator) Next() bool {
+	if it.index < len(it.keys) {
+		it.index++
+	}
+	return it.index < len(it.keys)
+}
+
+// Key returns key of current position.
+func (it *memoryIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return []byte(it.keys[it.index])
+}
+
+// Value returns value of current position.
+func (it *memoryIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+// Error returns error of iterator.
+func (it *memoryIterator) Error() error {
+	return nil
+}
+
+// Release releases iterator.
+func (it *memoryIterator) Release() {
+	it.keys = nil
+	it.values = nil
+}
@@ -16,6 +16,8 @@
 package store
 
 import (
+	"encoding/binary"
+
 	"airman.com/airtask/node/common"
 )
 
@@ -27,8 +29,34 @@ var (
 	resultKey = []byte("result")
 
 	// prefixes
-	TaskPrefix   = []byte("t") // taskPrefix + uuid -> task
-	ResultPrefix = []byte("r") // ResultPrefix + uuid -> result
+	TaskPrefix    = []byte("t") // taskPrefix + uuid -> task
+	ResultPrefix  = []byte("r") // ResultPrefix + uuid -> result
+	HistoryPrefix = []byte("h") // HistoryPrefix + uuid + run -> result
+	IndexPrefix   = []byte("i") // IndexPrefix + index key -> nil
+	MetaPrefix    = []byte("m") // MetaPrefix + name -> meta data
+	AuditPrefix   = []byte("a") // AuditPrefix + seq -> audit entry
+	EventPrefix   = []byte("e") // EventPrefix + seq -> event
+	WebhookPrefix = []byte("w") // WebhookPrefix + seq -> webhook delivery
+	QueuePrefix   = []byte("q") // QueuePrefix + seq -> nil, pending webhook delivery
+
+	// SchemaVersionKey is key of schema version in meta store.
+	SchemaVersionKey = []byte("version")
+
+	// AuditSeqKey is key of last sequence of audit log in meta store.
+	AuditSeqKey = []byte("audit")
+
+	// EventSeqKey is key of last sequence of event log in meta store.
+	EventSeqKey = []byte("event")
+
+	// WebhookSeqKey is key of last sequence of webhook deliveries in meta store.
+	WebhookSeqKey = []byte("webhook")
+)
+
+// layouts of index key
+const (
+	nameIndex  = 'n' // nameIndex + len(name) + name + uuid
+	stateIndex = 's' // stateIndex + len(state) + state + uuid
+	fireIndex  = 'f' // fireIndex + next fire time + uuid
 )
 
 // TaskKey return task key
@@ -40,3 +68,79 @@ func TaskKey(number uint64) []byte {
 func ResultKey(number uint64) []byte {
 	return append(ResultPrefix, common.EncodeItemID(number).Bytes()...)
 }
+
+// RunKey return key of result run in history store.
+func RunKey(number uint64, run uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], number)
+	binary.BigEndian.PutUint64(key[8:], run)
+	return key
+}
+
+// AuditKey return key of audit entry.
+func AuditKey(seq uint64) []byte {
+	return SeqKey(seq)
+}
+
+// EventKey return key of event.
+func EventKey(seq uint64) []byte {
+	return SeqKey(seq)
+}
+
+// SeqKey return key of sequence.
+func SeqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// NameIndexPrefix return prefix of name index key.
+func NameIndexPrefix(name string) []byte {
+	return stringIndexPrefix(nameIndex, name)
+}
+
+// NameIndexKey return name index key.
+func NameIndexKey(name string, number uint64) []byte {
+	return append(NameIndexPrefix(name), common.EncodeItemID(number).Bytes()...)
+}
+
+// StateIndexPrefix return prefix of state index key.
+func StateIndexPrefix(state string) []byte {
+	return stringIndexPrefix(stateIndex, state)
+}
+
+// StateIndexKey return state index key.
+func StateIndexKey(state string, number uint64) []byte {
+	return append(StateIndexPrefix(state), common.EncodeItemID(number).Bytes()...)
+}
+
+// FireIndexPrefix return prefix of next fire time index key.
+func FireIndexPrefix() []byte {
+	return []byte{fireIndex}
+}
+
+// FireIndexKey return next fire time index key, time is unix seconds.
+func FireIndexKey(fire int64, number uint64) []byte {
+	key := make([]byte, 17)
+	key[0] = fireIndex
+	binary.BigEndian.PutUint64(key[1:9], uint64(fire))
+	binary.BigEndian.PutUint64(key[9:], number)
+	return key
+}
+
+// FireIndexTime return next fire time of fire index key.
+func FireIndexTime(key []byte) int64 {
+	return int64(binary.BigEndian.Uint64(key[1:9]))
+}
+
+// IndexNumber return uuid of index key.
+func IndexNumber(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[len(key)-8:])
+}
+
+func stringIndexPrefix(index byte, s string) []byte {
+	key := make([]byte, 3, 3+len(s))
+	key[0] = index
+	binary.BigEndian.PutUint16(key[1:3], uint16(len(s)))
+	return append(key, s...)
+}
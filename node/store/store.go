@@ -15,18 +15,14 @@
 // along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
 package store
 
-import (
-	"airman.com/airfk/pkg/leveldb"
-)
-
 // Store is a wrapper as table.
 type Store struct {
-	db     *leveldb.LevelDB
+	db     Backend
 	prefix []byte
 }
 
 // NewStore returns a database object.
-func NewStore(db *leveldb.LevelDB, prefix []byte) *Store {
+func NewStore(db Backend, prefix []byte) *Store {
 	return &Store{
 		db:     db,
 		prefix: prefix,
@@ -35,26 +31,71 @@ func NewStore(db *leveldb.LevelDB, prefix []byte) *Store {
 
 // Close ...
 func (t *Store) Close() error {
-	t.db.Close()
-	return nil
+	return t.db.Close()
 }
 
 // Has retrieves if a prefixed key.
 func (t *Store) Has(key []byte) (bool, error) {
-	return t.db.Has(append(t.prefix, key...))
+	return t.db.Has(t.key(key))
 }
 
 // Get retrieves the given prefixed key.
 func (t *Store) Get(key []byte) ([]byte, error) {
-	return t.db.Get(append(t.prefix, key...))
+	return t.db.Get(t.key(key))
 }
 
 // Put inserts the given value into the database.
 func (t *Store) Put(key []byte, value []byte) error {
-	return t.db.Put(append(t.prefix, key...), value)
+	return t.db.Put(t.key(key), value)
 }
 
 // Delete removes the given prefixed key from the database.
 func (t *Store) Delete(key []byte) error {
-	return t.db.Delete(append(t.prefix, key...))
+	return t.db.Delete(t.key(key))
+}
+
+// Iterate calls fn with each key and value which have the given prefix, in key order,
+// starting from the start key. The keys passed to fn are without store prefix, and
+// iteration stops if fn returns false.
+func (t *Store) Iterate(prefix []byte, start []byte, fn func(key, value []byte) bool) error {
+	return t.iterate(t.db, prefix, start, fn)
+}
+
+// NewSnapshot creates a snapshot of backend of store, it is shared by stores on
+// the same backend and must be released after use.
+func (t *Store) NewSnapshot() (Snapshot, error) {
+	return t.db.NewSnapshot()
+}
+
+// IterateSnapshot is Iterate over snapshot created by NewSnapshot.
+func (t *Store) IterateSnapshot(snap Snapshot, prefix []byte, start []byte, fn func(key, value []byte) bool) error {
+	return t.iterate(snap, prefix, start, fn)
+}
+
+func (t *Store) iterate(r Reader, prefix []byte, start []byte, fn func(key, value []byte) bool) error {
+	it := r.NewIterator(t.key(prefix))
+	defer it.Release()
+
+	var ok bool
+	if len(start) > 0 {
+		ok = it.Seek(t.key(start))
+	} else {
+		ok = it.First()
+	}
+
+	for ; ok; ok = it.Next() {
+		key := append([]byte(nil), it.Key()[len(t.prefix):]...)
+		value := append([]byte(nil), it.Value()...)
+		if !fn(key, value) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// key returns prefixed key.
+func (t *Store) key(key []byte) []byte {
+	k := make([]byte, 0, len(t.prefix)+len(key))
+	k = append(k, t.prefix...)
+	return append(k, key...)
 }
@@ -0,0 +1,163 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"airman.com/airtask/node/common"
+)
+
+func testBackend(t *testing.T, db Backend) {
+	tasks := NewStore(db, TaskPrefix)
+	index := NewStore(db, IndexPrefix)
+
+	b := tasks.NewBatch()
+	for i := uint64(1); i <= 5; i++ {
+		if err := b.Put(tasks, common.EncodeItemID(i).Bytes(), []byte(fmt.Sprintf("task%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Put(index, FireIndexKey(int64(100-i), i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tasks.Get([]byte("none")); err != ErrNotFound {
+		t.Fatalf("get error: %v", err)
+	}
+	if ok, _ := index.Has(FireIndexKey(99, 1)); !ok {
+		t.Fatal("no index")
+	}
+
+	// ordered by fire time, from the second key
+	var ids []uint64
+	start := FireIndexKey(96, 4)
+	err := index.Iterate(FireIndexPrefix(), start, func(key, value []byte) bool {
+		ids = append(ids, IndexNumber(key))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[4 3 2 1]" {
+		t.Fatalf("iterate ids: %v", ids)
+	}
+
+	// task prefix does not include index keys
+	var values [][]byte
+	err = tasks.Iterate(nil, nil, func(key, value []byte) bool {
+		values = append(values, value)
+		return len(values) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || !bytes.Equal(values[0], []byte("task1")) {
+		t.Fatalf("iterate values: %q", values)
+	}
+
+	snap, err := db.NewSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+
+	if err := tasks.Delete(common.EncodeItemID(1).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := tasks.Has(common.EncodeItemID(1).Bytes()); ok {
+		t.Fatal("task is not deleted")
+	}
+	if ok, _ := snap.Has(append(TaskPrefix, common.EncodeItemID(1).Bytes()...)); !ok {
+		t.Fatal("task is deleted in snapshot")
+	}
+	values = nil
+	err = tasks.IterateSnapshot(snap, nil, nil, func(key, value []byte) bool {
+		values = append(values, value)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) == 0 || !bytes.Equal(values[0], []byte("task1")) {
+		t.Fatalf("iterate snapshot values: %q", values)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestStorePrefix(t *testing.T) {
+	// prefix with spare capacity is shared by keys if it is appended to.
+	prefix := make([]byte, 1, 16)
+	prefix[0] = 'x'
+	tasks := NewStore(NewMemory(), prefix)
+
+	var wg sync.WaitGroup
+	for i := uint64(1); i <= 8; i++ {
+		wg.Add(1)
+		go func(i uint64) {
+			defer wg.Done()
+			key := common.EncodeItemID(i).Bytes()
+			if err := tasks.Put(key, []byte(fmt.Sprintf("task%d", i))); err != nil {
+				t.Error(err)
+			}
+			if ok, err := tasks.Has(key); err != nil || !ok {
+				t.Errorf("has task %d: %v, %v", i, ok, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := uint64(1); i <= 8; i++ {
+		value, err := tasks.Get(common.EncodeItemID(i).Bytes())
+		if err != nil || string(value) != fmt.Sprintf("task%d", i) {
+			t.Errorf("task %d: %q, %v", i, value, err)
+		}
+	}
+	if err := tasks.Delete(common.EncodeItemID(1).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := tasks.Has(common.EncodeItemID(2).Bytes()); !ok {
+		t.Error("task 2 is deleted with task 1")
+	}
+}
+
+func TestBolt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "airtask")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := NewBolt(filepath.Join(dir, "task.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testBackend(t, db)
+}
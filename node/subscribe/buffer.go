@@ -0,0 +1,178 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package subscribe
+
+import (
+	"errors"
+	"sync"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/metrics"
+)
+
+// Policy is what is done when buffer of a subscriber is full.
+type Policy string
+
+const (
+	// PolicyDropOldest drops the oldest buffered event for the new one.
+	PolicyDropOldest Policy = "dropOldest"
+	// PolicyDropNewest drops the new event.
+	PolicyDropNewest Policy = "dropNewest"
+	// PolicyDisconnect drops the subscriber.
+	PolicyDisconnect Policy = "disconnect"
+)
+
+const (
+	// DefaultBufferSize is default number of buffered events per subscriber.
+	DefaultBufferSize = 256
+	// DefaultPolicy is default overflow policy.
+	DefaultPolicy = PolicyDropOldest
+)
+
+var (
+	ErrInvalidPolicy = cmn.NewInvalidError("overflow", "invalid overflow policy")
+	ErrDisconnected  = errors.New("subscription disconnected by server for slow consumer")
+)
+
+// Config is setting of buffers of subscribers, zero value is default.
+type Config struct {
+	BufferSize int    // max buffered events per subscriber
+	Policy     Policy // overflow policy of subscriber buffer
+}
+
+// Overflow is overflow state of a subscriber.
+type Overflow struct {
+	Policy       Policy `json:"policy"`
+	Dropped      uint64 `json:"dropped"`                // total dropped items
+	Disconnected bool   `json:"disconnected,omitempty"` // subscriber is dropped
+}
+
+// OverflowNotice is notification sent to subscriber whose events are dropped.
+// It is the last notification of disconnected subscriber, Error is set then.
+type OverflowNotice struct {
+	Overflow Overflow `json:"overflow"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// NewOverflowNotice returns notice of overflow state.
+func NewOverflowNotice(state Overflow) *OverflowNotice {
+	notice := &OverflowNotice{Overflow: state}
+	if state.Disconnected {
+		notice.Error = ErrDisconnected.Error()
+	}
+	return notice
+}
+
+// buffer is bounded queue of events between event loop and a subscriber, it is
+// never blocked by the subscriber.
+type buffer struct {
+	mu       sync.Mutex
+	items    []interface{}
+	size     int
+	policy   Policy
+	dropped  uint64
+	closed   bool          // closed by disconnect policy
+	ready    chan struct{} // signaled when items are pushed
+	overflow chan struct{} // signaled when items are dropped
+}
+
+func newBuffer(cfg Config) *buffer {
+	return &buffer{
+		size:     cfg.BufferSize,
+		policy:   cfg.Policy,
+		ready:    make(chan struct{}, 1),
+		overflow: make(chan struct{}, 1),
+	}
+}
+
+// push queues event, the overflow policy is applied if buffer is full.
+func (b *buffer) push(ev interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if len(b.items) < b.size {
+		b.items = append(b.items, ev)
+		signal(b.ready)
+		return
+	}
+
+	var dropped int
+	switch b.policy {
+	case PolicyDropOldest:
+		dropped = itemCount(b.items[0])
+		b.items = append(b.items[1:], ev)
+	case PolicyDropNewest:
+		dropped = itemCount(ev)
+	case PolicyDisconnect:
+		dropped = itemCount(ev)
+		for _, item := range b.items {
+			dropped += itemCount(item)
+		}
+		b.items = nil
+		b.closed = true
+		metrics.SubscribeDisconnectCounter.Inc(1)
+	}
+	b.dropped += uint64(dropped)
+	metrics.SubscribeDropCounter.Inc(int64(dropped))
+	signal(b.overflow)
+}
+
+// pop takes the oldest queued event, false is returned if buffer is empty. An
+// event is taken only when the previous one is sent, so at most size events
+// are queued and one is in flight.
+func (b *buffer) pop() (interface{}, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.items) == 0 {
+		return nil, false
+	}
+	ev := b.items[0]
+	b.items[0] = nil
+	b.items = b.items[1:]
+	return ev, true
+}
+
+// state returns overflow state.
+func (b *buffer) state() Overflow {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Overflow{Policy: b.policy, Dropped: b.dropped, Disconnected: b.closed}
+}
+
+// signal notifies channel without blocking.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// itemCount returns number of items in event, a batch of results or events is
+// counted by its length.
+func itemCount(ev interface{}) int {
+	switch e := ev.(type) {
+	case []cmn.Result:
+		return len(e)
+	case []cmn.Event:
+		return len(e)
+	}
+	return 1
+}
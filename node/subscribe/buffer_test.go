@@ -0,0 +1,172 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package subscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func drain(b *buffer) []interface{} {
+	var items []interface{}
+	for ev, ok := b.pop(); ok; ev, ok = b.pop() {
+		items = append(items, ev)
+	}
+	return items
+}
+
+func signaled(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestBufferOverflow(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		items   string
+		dropped uint64
+		closed  bool
+	}{
+		{PolicyDropOldest, "[2 3 4]", 1, false},
+		{PolicyDropNewest, "[1 2 3]", 1, false},
+		{PolicyDisconnect, "[]", 4, true},
+	}
+	for _, tt := range tests {
+		b := newBuffer(Config{BufferSize: 3, Policy: tt.policy})
+		for i := 0; i < 3; i++ {
+			b.push(i)
+		}
+		// event in flight leaves room for one more.
+		if ev, ok := b.pop(); !ok || ev != 0 {
+			t.Fatalf("%s: pop %v, %v, want 0", tt.policy, ev, ok)
+		}
+		b.push(3)
+		if signaled(b.overflow) {
+			t.Fatalf("%s: overflow before buffer is full", tt.policy)
+		}
+		b.push(4)
+		if !signaled(b.overflow) {
+			t.Errorf("%s: overflow is not signaled", tt.policy)
+		}
+
+		if items := fmt.Sprint(drain(b)); items != tt.items {
+			t.Errorf("%s: items %s, want %s", tt.policy, items, tt.items)
+		}
+		state := b.state()
+		if state.Policy != tt.policy || state.Dropped != tt.dropped || state.Disconnected != tt.closed {
+			t.Errorf("%s: state %+v", tt.policy, state)
+		}
+
+		// disconnected buffer takes no more events.
+		b.push(5)
+		if items := drain(b); tt.closed != (len(items) == 0) {
+			t.Errorf("%s: items after overflow %v", tt.policy, items)
+		}
+	}
+}
+
+func TestBufferBatchCount(t *testing.T) {
+	b := newBuffer(Config{BufferSize: 1, Policy: PolicyDropOldest})
+	b.push([]cmn.Event{{Seq: 1}, {Seq: 2}})
+	b.push([]cmn.Event{{Seq: 3}})
+	if state := b.state(); state.Dropped != 2 {
+		t.Fatalf("dropped %d, want 2", state.Dropped)
+	}
+}
+
+func TestOverflowNotice(t *testing.T) {
+	notice := &OverflowNotice{Overflow: Overflow{Policy: PolicyDropOldest, Dropped: 7}}
+	data, err := json.Marshal(notice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"overflow":{"policy":"dropOldest","dropped":7}}`; string(data) != want {
+		t.Fatalf("notice %s, want %s", data, want)
+	}
+
+	notice.Overflow.Policy, notice.Overflow.Disconnected = PolicyDisconnect, true
+	data, _ = json.Marshal(notice)
+	if want := `{"overflow":{"policy":"disconnect","dropped":7,"disconnected":true}}`; string(data) != want {
+		t.Fatalf("notice %s, want %s", data, want)
+	}
+}
+
+func TestSubscriptionOverflow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	es, err := NewEventMsgWithConfig(ctx, &TestBackend{ctx: ctx}, Config{BufferSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEventMsgWithConfig(ctx, &TestBackend{ctx: ctx}, Config{Policy: "block"}); err != ErrInvalidPolicy {
+		t.Fatalf("invalid policy: %v", err)
+	}
+	backend := es.backend.(*TestBackend)
+
+	slow := make(chan int64)
+	slowSub := es.SubscribeNewTask(slow)
+	defer slowSub.Unsubscribe()
+	fast := make(chan int64, 16)
+	fastSub := es.SubscribeNewTask(fast)
+	defer fastSub.Unsubscribe()
+
+	const last = 10
+	for i := int64(0); i <= last; i++ {
+		backend.addFeed.Send(i)
+	}
+	// all events are pushed to slow subscriber when fast one gets the last.
+	for id := int64(-1); id != last; {
+		select {
+		case id = <-fast:
+		case <-ctx.Done():
+			t.Fatal("last event is not received")
+		}
+	}
+
+	select {
+	case <-slowSub.Overflow():
+	case <-ctx.Done():
+		t.Fatal("overflow is not signaled")
+	}
+
+	var received []int64
+	for {
+		select {
+		case id := <-slow:
+			received = append(received, id)
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	state := slowSub.OverflowState()
+	if len(received) > 3 || received[len(received)-1] != last {
+		t.Errorf("received %v, want at most 3 ending with %d", received, last)
+	}
+	if int(state.Dropped)+len(received) != last+1 {
+		t.Errorf("dropped %d, received %d, want %d in total", state.Dropped, len(received), last+1)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package subscribe
+
+import (
+	"path"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+const (
+	// OutcomeSuccess matches results of successful run.
+	OutcomeSuccess = "success"
+	// OutcomeFailure matches results of failed run.
+	OutcomeFailure = "failure"
+)
+
+var (
+	ErrInvalidOutcome = cmn.NewInvalidError("outcome", "invalid outcome")
+)
+
+// Filter is criteria of result subscription, empty field matches everything.
+type Filter struct {
+	IDs     []int64     `json:"ids,omitempty"`     // uuid of tasks
+	Name    string      `json:"name,omitempty"`    // glob pattern of task name
+	Type    cmn.JobType `json:"type,omitempty"`    // type of task
+	Outcome string      `json:"outcome,omitempty"` // success or failure
+
+	ids map[int64]struct{}
+}
+
+// Validate checks criteria and prepares filter for matching.
+func (f *Filter) Validate() error {
+	if f.Name != "" {
+		if _, err := path.Match(f.Name, ""); err != nil {
+			return cmn.NewInvalidError("name", err.Error())
+		}
+	}
+	switch f.Outcome {
+	case "", OutcomeSuccess, OutcomeFailure:
+	default:
+		return ErrInvalidOutcome
+	}
+
+	f.ids = nil
+	if len(f.IDs) > 0 {
+		f.ids = make(map[int64]struct{}, len(f.IDs))
+		for _, id := range f.IDs {
+			f.ids[id] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// Match returns whether result matches criteria. Nil filter matches everything.
+func (f *Filter) Match(r *cmn.Result) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.IDs) > 0 {
+		if f.ids != nil {
+			if _, ok := f.ids[r.ID]; !ok {
+				return false
+			}
+		} else if !containsID(f.IDs, r.ID) {
+			return false
+		}
+	}
+	if f.Name != "" {
+		if ok, _ := path.Match(f.Name, r.Name); !ok {
+			return false
+		}
+	}
+	if f.Type != cmn.JobTypeUnkown && f.Type != r.Type {
+		return false
+	}
+	switch f.Outcome {
+	case OutcomeSuccess:
+		return r.Succeeded()
+	case OutcomeFailure:
+		return !r.Succeeded()
+	}
+	return true
+}
+
+// Results returns results which match criteria, results is returned as it is
+// when filter is nil.
+func (f *Filter) Results(results []cmn.Result) []cmn.Result {
+	if f == nil {
+		return results
+	}
+	matched := make([]cmn.Result, 0, len(results))
+	for i := range results {
+		if f.Match(&results[i]) {
+			matched = append(matched, results[i])
+		}
+	}
+	return matched
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package subscribe
+
+import (
+	"fmt"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func TestFilter(t *testing.T) {
+	ok := cmn.ToMsg(nil)
+	results := []cmn.Result{
+		{ID: 1, Name: "backup-db", Type: cmn.JobTypeCmd, ErrorMsg: ok},
+		{ID: 2, Name: "backup-files", Type: cmn.JobTypeFile, ErrorMsg: "exit status 1"},
+		{ID: 3, Name: "report", Type: cmn.JobTypePlugin, ErrorMsg: ok},
+		{ID: 4, Name: "report/daily", Type: cmn.JobTypeCmd, ErrorMsg: "timeout"},
+	}
+
+	tests := []struct {
+		name   string
+		filter *Filter
+		ids    string
+	}{
+		{"nil", nil, "[1 2 3 4]"},
+		{"empty", &Filter{}, "[1 2 3 4]"},
+		{"glob", &Filter{Name: "backup-*"}, "[1 2]"},
+		{"glob class", &Filter{Name: "backup-[d]*"}, "[1]"},
+		{"glob not separator", &Filter{Name: "report*"}, "[3]"},
+		{"exact name", &Filter{Name: "report/daily"}, "[4]"},
+		{"ids", &Filter{IDs: []int64{2, 4, 5}}, "[2 4]"},
+		{"type", &Filter{Type: cmn.JobTypeCmd}, "[1 4]"},
+		{"success", &Filter{Outcome: OutcomeSuccess}, "[1 3]"},
+		{"failure", &Filter{Outcome: OutcomeFailure}, "[2 4]"},
+		{"all criteria", &Filter{IDs: []int64{1, 2, 4}, Name: "backup-*", Type: cmn.JobTypeFile, Outcome: OutcomeFailure}, "[2]"},
+		{"no match", &Filter{IDs: []int64{3}, Outcome: OutcomeFailure}, "[]"},
+	}
+	for _, tt := range tests {
+		if tt.filter != nil {
+			if err := tt.filter.Validate(); err != nil {
+				t.Fatalf("%s: validate: %v", tt.name, err)
+			}
+		}
+		matched := tt.filter.Results(results)
+		ids := make([]int64, len(matched))
+		for i := range matched {
+			ids[i] = matched[i].ID
+		}
+		if got := fmt.Sprint(ids); got != tt.ids {
+			t.Errorf("%s: matched %s, want %s", tt.name, got, tt.ids)
+		}
+	}
+}
+
+func TestFilterUnvalidated(t *testing.T) {
+	// ids are matched without the index built by Validate.
+	f := &Filter{IDs: []int64{7}}
+	if !f.Match(&cmn.Result{ID: 7}) || f.Match(&cmn.Result{ID: 8}) {
+		t.Fatal("ids are not matched before validate")
+	}
+}
+
+func TestFilterInvalid(t *testing.T) {
+	tests := []struct {
+		filter *Filter
+		field  string
+	}{
+		{&Filter{Name: "backup-["}, "name"},
+		{&Filter{Name: `report\`}, "name"},
+		{&Filter{Outcome: "done"}, "outcome"},
+	}
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if err == nil {
+			t.Errorf("%+v: no error", tt.filter)
+			continue
+		}
+		e, ok := err.(*cmn.Error)
+		if !ok || e.Code != cmn.CodeInvalid || e.Data == nil || e.Data.Field != tt.field {
+			t.Errorf("%+v: error %v, want invalid %s", tt.filter, err, tt.field)
+		}
+	}
+}
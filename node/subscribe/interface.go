@@ -30,4 +30,10 @@ type Backend interface {
 
 	// SubscribeNewEvent registers a subscription of task results.
 	SubscribeNewEvent(ch chan<- int64) event.Subscription
+
+	// SubscribeLifecycleEvent registers a subscription of lifecycle of tasks and modules.
+	SubscribeLifecycleEvent(ch chan<- cmn.LifecycleEvent) event.Subscription
+
+	// SubscribeEvents registers a subscription of events appended to event log.
+	SubscribeEvents(ch chan<- []cmn.Event) event.Subscription
 }
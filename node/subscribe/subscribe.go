@@ -57,6 +57,10 @@ const (
 	ResultsTaskSubscription
 	// NewTaskSubscription
 	NewTaskSubscription
+	// LifecycleSubscription
+	LifecycleSubscription
+	// EventLogSubscription
+	EventLogSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -67,6 +71,10 @@ const (
 	addEvChanSize = 128
 	// resultEvChanSize is the size of channel listening to task result.
 	resultEvChanSize = 64
+	// lifecycleEvChanSize is the size of channel listening to lifecycle event.
+	lifecycleEvChanSize = 128
+	// eventLogEvChanSize is the size of channel listening to event log.
+	eventLogEvChanSize = 64
 )
 
 var (
@@ -78,25 +86,36 @@ type subscription struct {
 	typ       Type
 	created   time.Time
 	results   chan []cmn.Result
+	filter    *Filter // criteria of results, nil matches everything
 	adds      chan int64
+	lifecycle chan cmn.LifecycleEvent
+	types     map[cmn.LifecycleType]bool // types of lifecycle events, nil is all
+	events    chan []cmn.Event
+	buf       *buffer       // events waiting for delivery
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
+	delivered chan struct{} // closed when delivery loop exits
 }
 
 // EventMsg creates subscriptions, processes events and broadcasts them to the
 // subscription which match the subscription criteria.
 type EventMsg struct {
 	backend Backend
+	config  Config
 
 	// Subscriptions
 	resultsSub event.Subscription // Subscription for result task event
 	addsSub    event.Subscription // Subscription for new task event
+	lifeSub    event.Subscription // Subscription for lifecycle event
+	logSub     event.Subscription // Subscription for event log
 
 	// Channels
-	install   chan *subscription // install filter for event notification
-	uninstall chan *subscription // remove filter for event notification
-	resultsCh chan []cmn.Result  // Channel to receive new task result event
-	addsCh    chan int64         // Channel to receive new task event
+	install   chan *subscription      // install filter for event notification
+	uninstall chan *subscription      // remove filter for event notification
+	resultsCh chan []cmn.Result       // Channel to receive new task result event
+	addsCh    chan int64              // Channel to receive new task event
+	lifeCh    chan cmn.LifecycleEvent // Channel to receive lifecycle event
+	logCh     chan []cmn.Event        // Channel to receive events of event log
 	index     eventIndex
 
 	mu sync.Mutex
@@ -111,21 +130,42 @@ type eventIndex map[Type]map[server.ID]*subscription
 // The returned manager has a loop that needs to be stopped with the Stop function
 // or by stopping the given mux.
 func NewEventMsg(ctx context.Context, backend Backend) (*EventMsg, error) {
+	return NewEventMsgWithConfig(ctx, backend, Config{})
+}
+
+// NewEventMsgWithConfig creates EventMsg with setting of subscriber buffers.
+func NewEventMsgWithConfig(ctx context.Context, backend Backend, config Config) (*EventMsg, error) {
+	if config.BufferSize <= 0 {
+		config.BufferSize = DefaultBufferSize
+	}
+	switch config.Policy {
+	case "":
+		config.Policy = DefaultPolicy
+	case PolicyDropOldest, PolicyDropNewest, PolicyDisconnect:
+	default:
+		return nil, ErrInvalidPolicy
+	}
+
 	m := &EventMsg{
 		backend:   backend,
+		config:    config,
 		install:   make(chan *subscription),
 		uninstall: make(chan *subscription),
 		resultsCh: make(chan []cmn.Result, resultEvChanSize),
 		addsCh:    make(chan int64, addEvChanSize),
+		lifeCh:    make(chan cmn.LifecycleEvent, lifecycleEvChanSize),
+		logCh:     make(chan []cmn.Event, eventLogEvChanSize),
 		index:     make(eventIndex),
 	}
 
 	// Subscribe events
 	m.resultsSub = m.backend.SubscribeResultEvent(m.resultsCh)
 	m.addsSub = m.backend.SubscribeNewEvent(m.addsCh)
+	m.lifeSub = m.backend.SubscribeLifecycleEvent(m.lifeCh)
+	m.logSub = m.backend.SubscribeEvents(m.logCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.resultsSub == nil || m.addsSub == nil {
+	if m.resultsSub == nil || m.addsSub == nil || m.lifeSub == nil || m.logSub == nil {
 		return nil, errors.New("subscribe for event system failed")
 	}
 
@@ -150,6 +190,17 @@ func (sub *Subscription) Err() <-chan error {
 	return sub.f.err
 }
 
+// Overflow returns a channel that is signaled when events of subscriber are
+// dropped by overflow policy.
+func (sub *Subscription) Overflow() <-chan struct{} {
+	return sub.f.buf.overflow
+}
+
+// OverflowState returns overflow state of subscriber.
+func (sub *Subscription) OverflowState() Overflow {
+	return sub.f.buf.state()
+}
+
 // Unsubscribe uninstalls the subscription from the event broadcast loop.
 func (sub *Subscription) Unsubscribe() {
 	sub.unsubOnce.Do(func() {
@@ -163,6 +214,9 @@ func (sub *Subscription) Unsubscribe() {
 			case sub.es.uninstall <- sub.f:
 				break uninstallLoop
 			case <-sub.f.results:
+			case <-sub.f.adds:
+			case <-sub.f.lifecycle:
+			case <-sub.f.events:
 			}
 		}
 
@@ -170,27 +224,96 @@ func (sub *Subscription) Unsubscribe() {
 		// this ensures that the manager won't use the event channel which
 		// will probably be closed by the client asap after this method returns.
 		<-sub.Err()
+		<-sub.f.delivered
 	})
 }
 
 // subscribe installs the subscription in the event broadcast loop.
 func (es *EventMsg) subscribe(sub *subscription) *Subscription {
+	sub.buf = newBuffer(es.config)
+	sub.delivered = make(chan struct{})
+	go es.deliver(sub)
+
 	es.install <- sub
 	<-sub.installed
 	return &Subscription{ID: sub.id, f: sub, es: es}
 }
 
+// deliver sends buffered events to subscriber until it is uninstalled, a slow
+// subscriber blocks only its own delivery.
+func (es *EventMsg) deliver(f *subscription) {
+	defer close(f.delivered)
+
+	for {
+		select {
+		case <-f.buf.ready:
+			for ev, ok := f.buf.pop(); ok; ev, ok = f.buf.pop() {
+				if !f.send(ev) {
+					return
+				}
+			}
+		case <-f.err:
+			return
+		}
+	}
+}
+
+// send sends event to channel of subscriber, false is returned if subscriber
+// is uninstalled.
+func (f *subscription) send(ev interface{}) bool {
+	switch e := ev.(type) {
+	case []cmn.Result:
+		select {
+		case f.results <- e:
+		case <-f.err:
+			return false
+		}
+	case int64:
+		select {
+		case f.adds <- e:
+		case <-f.err:
+			return false
+		}
+	case cmn.LifecycleEvent:
+		select {
+		case f.lifecycle <- e:
+		case <-f.err:
+			return false
+		}
+	case []cmn.Event:
+		select {
+		case f.events <- e:
+		case <-f.err:
+			return false
+		}
+	}
+	return true
+}
+
 // SubscribeResultTask creates a subscription that transports result of task.
 func (es *EventMsg) SubscribeResultTask(results chan []cmn.Result) *Subscription {
+	sub, _ := es.SubscribeFilteredResults(results, nil)
+	return sub
+}
+
+// SubscribeFilteredResults creates a subscription that transports result of
+// task which matches filter, it is evaluated in event loop.
+func (es *EventMsg) SubscribeFilteredResults(results chan []cmn.Result, filter *Filter) (*Subscription, error) {
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
 	sub := &subscription{
 		id:        server.NewID(),
 		typ:       ResultsTaskSubscription,
 		created:   time.Now(),
 		results:   results,
+		filter:    filter,
 		installed: make(chan struct{}),
 		err:       make(chan error),
 	}
-	return es.subscribe(sub)
+	return es.subscribe(sub), nil
 }
 
 // SubscribeNewTask creates a subscription that transport event of new task.
@@ -206,6 +329,52 @@ func (es *EventMsg) SubscribeNewTask(adds chan int64) *Subscription {
 	return es.subscribe(sub)
 }
 
+// SubscribeLifecycle creates a subscription that transports lifecycle events of
+// the given types, all types if types is empty.
+func (es *EventMsg) SubscribeLifecycle(events chan cmn.LifecycleEvent, types []cmn.LifecycleType) *Subscription {
+	sub := &subscription{
+		id:        server.NewID(),
+		typ:       LifecycleSubscription,
+		created:   time.Now(),
+		lifecycle: events,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	if len(types) > 0 {
+		sub.types = make(map[cmn.LifecycleType]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeEventLog creates a subscription that transports events appended to
+// event log.
+func (es *EventMsg) SubscribeEventLog(events chan []cmn.Event) *Subscription {
+	sub := &subscription{
+		id:        server.NewID(),
+		typ:       EventLogSubscription,
+		created:   time.Now(),
+		events:    events,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// Count returns number of installed subscriptions.
+func (es *EventMsg) Count() int {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	n := 0
+	for _, subs := range es.index {
+		n += len(subs)
+	}
+	return n
+}
+
 // broadcast event to filters that match criteria.
 func (es *EventMsg) broadcast(ev interface{}) {
 	if ev == nil {
@@ -219,12 +388,30 @@ func (es *EventMsg) broadcast(ev interface{}) {
 			results = append(results, r)
 		}
 		for _, f := range es.index[ResultsTaskSubscription] {
-			f.results <- results
+			if f.filter == nil {
+				f.buf.push(results)
+				continue
+			}
+			if matched := f.filter.Results(results); len(matched) > 0 {
+				f.buf.push(matched)
+			}
 		}
 
 	case int64:
 		for _, f := range es.index[NewTaskSubscription] {
-			f.adds <- e
+			f.buf.push(e)
+		}
+
+	case cmn.LifecycleEvent:
+		for _, f := range es.index[LifecycleSubscription] {
+			if f.types == nil || f.types[e.Type] {
+				f.buf.push(e)
+			}
+		}
+
+	case []cmn.Event:
+		for _, f := range es.index[EventLogSubscription] {
+			f.buf.push(e)
 		}
 	}
 }
@@ -240,6 +427,12 @@ func (es *EventMsg) eventLoop(ctx context.Context) {
 		case ev := <-es.addsCh:
 			es.broadcast(ev)
 
+		case ev := <-es.lifeCh:
+			es.broadcast(ev)
+
+		case ev := <-es.logCh:
+			es.broadcast(ev)
+
 		case f := <-es.install:
 			es.mu.Lock()
 			es.index[f.typ][f.id] = f
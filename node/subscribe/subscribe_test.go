@@ -33,6 +33,23 @@ type TestBackend struct {
 
 	resultFeed  event.Feed              // Event feed to notify wallet additions/removals
 	resultScope event.SubscriptionScope // Subscription scope tracking current live listeners
+
+	addFeed       event.Feed
+	lifecycleFeed event.Feed
+	eventFeed     event.Feed
+	scope         event.SubscriptionScope
+}
+
+func (t *TestBackend) SubscribeNewEvent(ch chan<- int64) event.Subscription {
+	return t.scope.Track(t.addFeed.Subscribe(ch))
+}
+
+func (t *TestBackend) SubscribeLifecycleEvent(ch chan<- cmn.LifecycleEvent) event.Subscription {
+	return t.scope.Track(t.lifecycleFeed.Subscribe(ch))
+}
+
+func (t *TestBackend) SubscribeEvents(ch chan<- []cmn.Event) event.Subscription {
+	return t.scope.Track(t.eventFeed.Subscribe(ch))
 }
 
 func (t *TestBackend) SubscribeResultEvent(ch chan<- []cmn.Result) event.Subscription {
@@ -55,7 +72,7 @@ func (t *TestBackend) updater() {
 			return
 		}
 
-		r := cmn.NewResultWithEnd(time.Now().String(), time.Now().Unix(), time.Now().Unix(), "ok", []byte("output is 2046"))
+		r := cmn.NewResultWithEnd(time.Now().UnixNano(), time.Now().Unix(), time.Now().Unix(), "ok", []byte("output is 2046"))
 		t.resultFeed.Send([]cmn.Result{*r})
 	}
 }
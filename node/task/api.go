@@ -17,7 +17,7 @@ package task
 
 import (
 	"context"
-	"errors"
+	"sync/atomic"
 	"time"
 
 	"airman.com/airfk/pkg/common/hexutil"
@@ -26,6 +26,8 @@ import (
 
 	cmn "airman.com/airtask/node/common"
 	"airman.com/airtask/node/metrics"
+	"airman.com/airtask/node/store"
+	fs "airman.com/airtask/node/subscribe"
 )
 
 // PrivateAdminAPI is the collection of administrative API methods exposed only
@@ -42,13 +44,14 @@ func NewPrivateTaskAPI(manager *Manager) *PrivateTaskAPI {
 
 // Job is task job.
 type JobArgs struct {
-	Name     *string        `json:"name"`
-	Extra    *hexutil.Bytes `json:"extra"`
-	Type     *string        `json:"type"`
-	UUID     uint64         `json:"uuid"`
-	Datetime int64          `json:"datetime"`
-	Retry    int            `json:"retry"`
-	Interval int            `json:"interval"`
+	Name     *string     `json:"name"`
+	Extra    *ExtraArg   `json:"extra"` // hex, plain string or JSON object
+	Type     *string     `json:"type"`
+	UUID     uint64      `json:"uuid"`
+	Datetime DatetimeArg `json:"datetime"` // unix seconds or RFC3339
+	Retry    int         `json:"retry"`
+	Interval IntervalArg `json:"interval"` // seconds, Go or ISO-8601 duration
+	Webhooks []string    `json:"webhooks"`
 }
 
 // toJob convert args to job.
@@ -57,13 +60,13 @@ func (args *JobArgs) toJob(isAdd bool) (*cmn.Job, error) {
 
 	// check name
 	if args.Name == nil {
-		return nil, errors.New("no name field")
+		return nil, cmn.NewInvalidError("name", "no name field")
 	}
 
 	if isAdd {
 		var jobType cmn.JobType
 		if args.Type == nil {
-			return nil, errors.New("no type field")
+			return nil, cmn.NewInvalidError("type", "no type field")
 		} else {
 			switch *args.Type {
 			case "cmd":
@@ -73,22 +76,26 @@ func (args *JobArgs) toJob(isAdd bool) (*cmn.Job, error) {
 			case "plugin":
 				jobType = cmn.JobTypePlugin
 			default:
-				return nil, errors.New("invalid type field")
+				return nil, cmn.NewInvalidError("type", "invalid type field")
 			}
 		}
 
+		if args.Extra == nil {
+			return nil, cmn.NewInvalidError("extra", "no extra field")
+		}
+
 		retry := args.Retry
 		if retry == 0 {
 			retry = 1
 		}
 
-		interval := args.Interval
+		interval := int(args.Interval)
 		if interval == 0 {
 			interval = 1
 		}
 
 		if args.Datetime > 0 {
-			dt := time.Unix(args.Datetime, 0)
+			dt := time.Unix(int64(args.Datetime), 0)
 			now := time.Now()
 
 			if !dt.After(now) {
@@ -103,23 +110,46 @@ func (args *JobArgs) toJob(isAdd bool) (*cmn.Job, error) {
 			Retry:    retry,
 			Interval: interval,
 			AddTime:  time.Now().Unix(),
+			Webhooks: args.Webhooks,
 			Extra:    *args.Extra,
 		}, nil
 	}
 
 	if args.UUID == 0 {
-		return nil, errors.New("invalid uuid field")
+		return nil, cmn.NewInvalidError("uuid", "invalid uuid field")
 	}
 	return &cmn.Job{
 		UUID: cmn.EncodeItemID(args.UUID),
 	}, nil
 }
 
+// taskError converts error of the given task into typed error of task API, so
+// clients can tell missing task apart from storage failure.
+func (m *Manager) taskError(kind string, id int64, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*cmn.Error); ok {
+		return err
+	}
+	if err == store.ErrNotFound {
+		return cmn.NewNotFoundError(kind, id)
+	}
+	if atomic.LoadInt32(&m.ready) == 0 {
+		return ErrManagerNotRunning
+	}
+	return err
+}
+
 // AddTask adds a task
-func (api *PrivateTaskAPI) AddTask(args JobArgs) (int64, error) {
+func (api *PrivateTaskAPI) AddTask(ctx context.Context, args JobArgs) (id int64, err error) {
 	// metric
 	metrics.TaskAddMeter.Mark(1)
 
+	defer func() {
+		api.manager.Audit(ctx, "task_addTask", map[string]interface{}{"args": args, "uuid": id}, err)
+	}()
+
 	job, err := args.toJob(true)
 	if err != nil {
 		return 0, err
@@ -133,7 +163,18 @@ func (api *PrivateTaskAPI) GetTask(args JobArgs) (map[string]interface{}, error)
 	if err != nil {
 		return nil, err
 	}
-	return api.manager.GetTask(job)
+	info, err := api.manager.GetTask(job)
+	return info, api.manager.taskError("task", job.UUID.Int64(), err)
+}
+
+// GetTaskV2 get task as typed object with computed fields
+func (api *PrivateTaskAPI) GetTaskV2(args JobArgs) (*cmn.TaskDetail, error) {
+	job, err := args.toJob(false)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := api.manager.GetTaskV2(job.UUID.Int64())
+	return detail, api.manager.taskError("task", job.UUID.Int64(), err)
 }
 
 // CheckTask check task is existed or not
@@ -142,16 +183,47 @@ func (api *PrivateTaskAPI) CheckTask(args JobArgs) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return api.manager.CheckTask(job)
+	ok, err := api.manager.CheckTask(job)
+	return ok, api.manager.taskError("task", job.UUID.Int64(), err)
 }
 
 // DeleteTask delete task by id
-func (api *PrivateTaskAPI) DeleteTask(args JobArgs) error {
+func (api *PrivateTaskAPI) DeleteTask(ctx context.Context, args JobArgs) (err error) {
+	defer func() {
+		api.manager.Audit(ctx, "task_deleteTask", args, err)
+	}()
+
 	job, err := args.toJob(false)
 	if err != nil {
 		return err
 	}
-	return api.manager.DeleteTask(job)
+	return api.manager.taskError("task", job.UUID.Int64(), api.manager.DeleteTask(job))
+}
+
+// PauseTask pauses scheduled task by id
+func (api *PrivateTaskAPI) PauseTask(ctx context.Context, args JobArgs) (err error) {
+	defer func() {
+		api.manager.Audit(ctx, "task_pauseTask", args, err)
+	}()
+
+	job, err := args.toJob(false)
+	if err != nil {
+		return err
+	}
+	return api.manager.taskError("task", job.UUID.Int64(), api.manager.PauseTask(job.UUID.Int64()))
+}
+
+// ResumeTask resumes paused task by id
+func (api *PrivateTaskAPI) ResumeTask(ctx context.Context, args JobArgs) (err error) {
+	defer func() {
+		api.manager.Audit(ctx, "task_resumeTask", args, err)
+	}()
+
+	job, err := args.toJob(false)
+	if err != nil {
+		return err
+	}
+	return api.manager.taskError("task", job.UUID.Int64(), api.manager.ResumeTask(job.UUID.Int64()))
 }
 
 // GetTaskResult get task running result.
@@ -160,28 +232,152 @@ func (api *PrivateTaskAPI) GetResult(args JobArgs) (map[string]interface{}, erro
 	if err != nil {
 		return nil, err
 	}
-	return api.manager.GetResult(job)
+	info, err := api.manager.GetResult(job)
+	return info, api.manager.taskError("result", job.UUID.Int64(), err)
+}
+
+// GetResultV2 get last result of task as typed object with decoded output.
+func (api *PrivateTaskAPI) GetResultV2(args JobArgs) (*cmn.ResultDetail, error) {
+	job, err := args.toJob(false)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := api.manager.GetResultV2(job.UUID.Int64())
+	return detail, api.manager.taskError("result", job.UUID.Int64(), err)
+}
+
+// ResultArgs is condition of listing results.
+type ResultArgs struct {
+	UUID  uint64 `json:"uuid"`
+	Start uint64 `json:"start"`
+	Limit int    `json:"limit"`
+	From  int64  `json:"from"`
+	To    int64  `json:"to"`
+}
+
+// ListResults lists running results of task.
+func (api *PrivateTaskAPI) ListResults(args ResultArgs) (*ResultPage, error) {
+	if args.UUID == 0 {
+		return nil, cmn.NewInvalidError("uuid", "invalid uuid field")
+	}
+	page, err := api.manager.ListResults(int64(args.UUID), &ResultQuery{
+		Start: args.Start,
+		Limit: args.Limit,
+		From:  args.From,
+		To:    args.To,
+	})
+	return page, api.manager.taskError("task", int64(args.UUID), err)
+}
+
+// DeliveryArgs is condition of listing webhook deliveries.
+type DeliveryArgs struct {
+	UUID   uint64 `json:"uuid"`
+	Status string `json:"status"`
+	Start  uint64 `json:"start"`
+	Limit  int    `json:"limit"`
+}
+
+// WebhookDeliveries lists webhook deliveries of results with their status.
+func (api *PrivateTaskAPI) WebhookDeliveries(args DeliveryArgs) (*DeliveryPage, error) {
+	switch args.Status {
+	case "", DeliveryPending, DeliveryDelivered, DeliveryFailed:
+	default:
+		return nil, cmn.NewInvalidError("status", "invalid status field")
+	}
+	return api.manager.Deliveries(&DeliveryQuery{
+		Start:  args.Start,
+		Limit:  args.Limit,
+		TaskID: int64(args.UUID),
+		Status: args.Status,
+	})
+}
+
+// ListArgs is condition of listing tasks.
+type ListArgs struct {
+	Name     string         `json:"name"`
+	Type     *string        `json:"type"`
+	State    *string        `json:"state"`
+	FireFrom int64          `json:"fire_from"`
+	FireTo   int64          `json:"fire_to"`
+	Cursor   *hexutil.Bytes `json:"cursor"`
+	Limit    int            `json:"limit"`
+}
+
+// ListTasks lists tasks by filters.
+func (api *PrivateTaskAPI) ListTasks(args ListArgs) (*TaskPage, error) {
+	q := &TaskQuery{
+		Name:     args.Name,
+		FireFrom: args.FireFrom,
+		FireTo:   args.FireTo,
+		Limit:    args.Limit,
+	}
+	if args.Cursor != nil {
+		q.Cursor = *args.Cursor
+	}
+	if args.Type != nil {
+		if err := q.Type.UnmarshalText([]byte(*args.Type)); err != nil {
+			return nil, cmn.NewInvalidError("type", "invalid type field")
+		}
+	}
+	if args.State != nil {
+		if err := q.State.UnmarshalText([]byte(*args.State)); err != nil {
+			return nil, cmn.NewInvalidError("state", "invalid state field")
+		}
+	}
+	return api.manager.ListTasks(q)
+}
+
+// EventArgs is option of subscription which replays event log.
+type EventArgs struct {
+	FromSeq uint64 `json:"fromSeq"` // first sequence of replay, 0 is no replay
+}
+
+// ResultsArgs is option of results subscription, results which do not match
+// filter are not sent.
+type ResultsArgs struct {
+	FromSeq *uint64 `json:"fromSeq"` // first sequence of replay, 0 is no replay
+	fs.Filter
 }
 
-// Results creates a subscription that is result of task.
-func (api *PrivateTaskAPI) Results(ctx context.Context) (*server.Subscription, error) {
+// Results creates a subscription that is result of task. If fromSeq is given,
+// notifications are events with sequence, and events in event log from
+// sequence fromSeq are replayed before live events.
+func (api *PrivateTaskAPI) Results(ctx context.Context, args *ResultsArgs) (*server.Subscription, error) {
+	var filter *fs.Filter
+	if args != nil {
+		filter = &args.Filter
+		if err := filter.Validate(); err != nil {
+			return nil, err
+		}
+		if args.FromSeq != nil {
+			return api.subscribeEvents(ctx, cmn.EventResult, *args.FromSeq, filter)
+		}
+	}
+
 	notifier, supported := server.NotifierFromContext(ctx)
 	if !supported {
 		return &server.Subscription{}, server.ErrNotificationsUnsupported
 	}
 
+	results := make(chan []cmn.Result, 128)
+	resultsSub, err := api.manager.es.SubscribeFilteredResults(results, filter)
+	if err != nil {
+		return nil, err
+	}
+
 	rpcSub := notifier.CreateSubscription()
 
 	go func() {
-		results := make(chan []cmn.Result, 128)
-		resultsSub := api.manager.es.SubscribeResultTask(results)
-
 		for {
 			select {
 			case rs := <-results:
 				for _, h := range rs {
 					notifier.Notify(rpcSub.ID, h)
 				}
+			case <-resultsSub.Overflow():
+				if notifyOverflow(notifier, rpcSub, resultsSub) {
+					return
+				}
 			case <-rpcSub.Err():
 				resultsSub.Unsubscribe()
 				return
@@ -195,8 +391,13 @@ func (api *PrivateTaskAPI) Results(ctx context.Context) (*server.Subscription, e
 	return rpcSub, nil
 }
 
-// Results creates a subscription that is result of task.
-func (api *PrivateTaskAPI) NewTask(ctx context.Context) (*server.Subscription, error) {
+// NewTask creates a subscription that is uuid of new task. If args is given,
+// notifications are events with sequence like Results.
+func (api *PrivateTaskAPI) NewTask(ctx context.Context, args *EventArgs) (*server.Subscription, error) {
+	if args != nil {
+		return api.subscribeEvents(ctx, cmn.EventNewTask, args.FromSeq, nil)
+	}
+
 	notifier, supported := server.NotifierFromContext(ctx)
 	if !supported {
 		return &server.Subscription{}, server.ErrNotificationsUnsupported
@@ -212,6 +413,10 @@ func (api *PrivateTaskAPI) NewTask(ctx context.Context) (*server.Subscription, e
 			select {
 			case t := <-tasks:
 				notifier.Notify(rpcSub.ID, t)
+			case <-resultsSub.Overflow():
+				if notifyOverflow(notifier, rpcSub, resultsSub) {
+					return
+				}
 			case <-rpcSub.Err():
 				resultsSub.Unsubscribe()
 				return
@@ -225,6 +430,192 @@ func (api *PrivateTaskAPI) NewTask(ctx context.Context) (*server.Subscription, e
 	return rpcSub, nil
 }
 
+// LifecycleArgs is option of lifecycle subscription.
+type LifecycleArgs struct {
+	Types []cmn.LifecycleType `json:"types"` // types of events, all if it is empty
+}
+
+// Lifecycle creates a subscription that is lifecycle events of tasks and modules,
+// e.g. a task is started, retrying or cancelled.
+func (api *PrivateTaskAPI) Lifecycle(ctx context.Context, args *LifecycleArgs) (*server.Subscription, error) {
+	notifier, supported := server.NotifierFromContext(ctx)
+	if !supported {
+		return &server.Subscription{}, server.ErrNotificationsUnsupported
+	}
+
+	var types []cmn.LifecycleType
+	if args != nil {
+		types = args.Types
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan cmn.LifecycleEvent, 128)
+		eventsSub := api.manager.es.SubscribeLifecycle(events, types)
+
+		for {
+			select {
+			case ev := <-events:
+				notifier.Notify(rpcSub.ID, ev)
+			case <-eventsSub.Overflow():
+				if notifyOverflow(notifier, rpcSub, eventsSub) {
+					return
+				}
+			case <-rpcSub.Err():
+				eventsSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				eventsSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// subscribeEvents creates a subscription of events of the given type, result
+// events are sent if result matches filter. Live events are subscribed first
+// and buffered while event log is replayed, events which are replayed already
+// are skipped by sequence.
+func (api *PrivateTaskAPI) subscribeEvents(ctx context.Context, typ string, fromSeq uint64, filter *fs.Filter) (*server.Subscription, error) {
+	notifier, supported := server.NotifierFromContext(ctx)
+	if !supported {
+		return &server.Subscription{}, server.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan []cmn.Event, 128)
+		eventsSub := api.manager.es.SubscribeEventLog(events)
+		defer eventsSub.Unsubscribe()
+
+		cursor := newEventCursor(api.manager, typ, filter, func(ev cmn.Event) {
+			notifier.Notify(rpcSub.ID, ev)
+		})
+		cursor.replay(fromSeq, events)
+
+		for {
+			select {
+			case evs := <-events:
+				cursor.notify(evs)
+			case <-eventsSub.Overflow():
+				if notifyOverflow(notifier, rpcSub, eventsSub) {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// rpcNotifier sends notifications of RPC subscriptions.
+type rpcNotifier interface {
+	Notify(id server.ID, data interface{}) error
+}
+
+// notifyOverflow sends overflow state to subscriber whose events are dropped,
+// true is returned if subscriber is disconnected by overflow policy. Notice of
+// disconnected subscriber carries an error, it is the last notification and
+// the client ends subscription with it.
+func notifyOverflow(notifier rpcNotifier, rpcSub *server.Subscription, sub *fs.Subscription) bool {
+	state := sub.OverflowState()
+	notifier.Notify(rpcSub.ID, fs.NewOverflowNotice(state))
+	if state.Disconnected {
+		log.Warnf("subscriber %s is disconnected by overflow, dropped %d", rpcSub.ID, state.Dropped)
+		sub.Unsubscribe()
+	}
+	return state.Disconnected
+}
+
+// PrivateArchiveAPI is the collection of backup methods of task database, it is
+// served in admin namespace.
+type PrivateArchiveAPI struct {
+	manager *Manager
+}
+
+// NewPrivateArchiveAPI creates new PrivateArchiveAPI.
+func NewPrivateArchiveAPI(manager *Manager) *PrivateArchiveAPI {
+	return &PrivateArchiveAPI{manager: manager}
+}
+
+// ExportTasks exports tasks and optionally results into archive file in archive
+// directory of node.
+func (api *PrivateArchiveAPI) ExportTasks(ctx context.Context, file string, results *bool) (stats *ArchiveStats, err error) {
+	withResults := results != nil && *results
+	defer func() {
+		api.manager.Audit(ctx, "admin_exportTasks", map[string]interface{}{"file": file, "results": withResults}, err)
+	}()
+
+	if file == "" {
+		return nil, cmn.NewInvalidError("file", "invalid file field")
+	}
+	path, err := api.manager.archivePath(file)
+	if err != nil {
+		return nil, err
+	}
+	return api.manager.ExportFile(path, withResults)
+}
+
+// ImportTasks imports tasks and results from archive file in archive directory
+// of node, conflict is skip (default), overwrite or renew.
+func (api *PrivateArchiveAPI) ImportTasks(ctx context.Context, file string, conflict *string) (stats *ArchiveStats, err error) {
+	policy := ConflictSkip
+	if conflict != nil {
+		policy = *conflict
+	}
+	defer func() {
+		api.manager.Audit(ctx, "admin_importTasks", map[string]interface{}{"file": file, "conflict": policy}, err)
+	}()
+
+	if file == "" {
+		return nil, cmn.NewInvalidError("file", "invalid file field")
+	}
+	path, err := api.manager.archivePath(file)
+	if err != nil {
+		return nil, err
+	}
+	return api.manager.ImportFile(path, policy)
+}
+
+// PrivateAuditAPI is the collection of audit log methods, it is served in admin namespace.
+type PrivateAuditAPI struct {
+	manager *Manager
+}
+
+// NewPrivateAuditAPI creates new PrivateAuditAPI.
+func NewPrivateAuditAPI(manager *Manager) *PrivateAuditAPI {
+	return &PrivateAuditAPI{manager: manager}
+}
+
+// AuditArgs is condition of listing audit log.
+type AuditArgs struct {
+	Start  uint64 `json:"start"`
+	Limit  int    `json:"limit"`
+	From   int64  `json:"from"`
+	To     int64  `json:"to"`
+	Action string `json:"action"`
+	Caller string `json:"caller"`
+}
+
+// AuditLog lists audit log of mutating calls.
+func (api *PrivateAuditAPI) AuditLog(args AuditArgs) (*AuditPage, error) {
+	return api.manager.AuditLog(&AuditQuery{
+		Start:  args.Start,
+		Limit:  args.Limit,
+		From:   args.From,
+		To:     args.To,
+		Action: args.Action,
+		Caller: args.Caller,
+	})
+}
+
 type PublicTaskAPI struct {
 	manager *Manager
 }
@@ -252,6 +643,7 @@ func (api *PublicTaskAPI) CheckModule(name string) (bool, error) {
 	return api.manager.CheckModule(name)
 }
 
-func (api *PublicTaskAPI) Stats() []string {
-	return nil
+// Stats returns live statistics of scheduler and executions.
+func (api *PublicTaskAPI) Stats() (*Stats, error) {
+	return api.manager.Stats()
 }
@@ -0,0 +1,372 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// ArchiveVersion is version of archive written by Export.
+const ArchiveVersion = 1
+
+// policies of importing task whose uuid is used by an existing task.
+const (
+	ConflictSkip      = "skip"      // keep existing task, archived task and its results are skipped
+	ConflictOverwrite = "overwrite" // replace existing task and its results by archived task
+	ConflictRenew     = "renew"     // import archived task with a new uuid
+)
+
+// kinds of archive record
+const (
+	recordTask   = "task"
+	recordResult = "result"
+)
+
+// DefaultArchiveDir is directory of archive files of RPC methods under data directory.
+const DefaultArchiveDir = "backups"
+
+var (
+	ErrArchiveVersion  = errors.New("unsupported archive version")
+	ErrInvalidConflict = cmn.NewInvalidError("conflict", "invalid conflict policy")
+	ErrArchivePath     = cmn.NewInvalidError("file", "archive file is not in archive directory")
+)
+
+// ArchiveHeader is first record of archive.
+type ArchiveHeader struct {
+	Version int    `json:"version"`
+	NodeID  string `json:"node_id"`
+	Created int64  `json:"created"`
+	Results bool   `json:"results"`
+}
+
+// archiveRecord is a task or a result run in archive. Script of task is
+// kept in extra of task.
+type archiveRecord struct {
+	Kind   string      `json:"kind"`
+	Job    *cmn.Job    `json:"job,omitempty"`
+	Result *cmn.Result `json:"result,omitempty"`
+}
+
+// ArchiveStats is what is exported or imported.
+type ArchiveStats struct {
+	Tasks   int `json:"tasks"`
+	Results int `json:"results"`
+	Skipped int `json:"skipped"` // tasks skipped by conflict
+	Renewed int `json:"renewed"` // tasks imported with a new uuid
+}
+
+// Export writes all tasks with their scripts, and results of every run if
+// results is true, to w as gzipped JSON lines. The first line is ArchiveHeader.
+// Tasks and results are read from snapshots of databases without lock.
+func (m *Manager) Export(w io.Writer, results bool) (*ArchiveStats, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	taskSnap, err := m.dbTask.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer taskSnap.Release()
+	resultSnap, err := m.dbHistory.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer resultSnap.Release()
+
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+
+	header := &ArchiveHeader{
+		Version: ArchiveVersion,
+		NodeID:  m.backend.NodeID(),
+		Created: time.Now().Unix(),
+		Results: results,
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	var (
+		stats = &ArchiveStats{}
+		werr  error
+	)
+	err = m.dbTask.IterateSnapshot(taskSnap, nil, nil, func(key, value []byte) bool {
+		job := new(cmn.Job)
+		if err := json.Unmarshal(value, job); err != nil {
+			log.Errorf("json unmarshal job error, %x, %v", key, err)
+			return true
+		}
+		job.UUID = cmn.EncodeItemID(binary.BigEndian.Uint64(key))
+		if werr = enc.Encode(&archiveRecord{Kind: recordTask, Job: job}); werr != nil {
+			return false
+		}
+		stats.Tasks++
+		return true
+	})
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if results {
+		err := m.dbHistory.IterateSnapshot(resultSnap, nil, nil, func(key, value []byte) bool {
+			r := new(cmn.Result)
+			if err := json.Unmarshal(value, r); err != nil {
+				log.Errorf("json unmarshal result error, %x, %v", key, err)
+				return true
+			}
+			if werr = enc.Encode(&archiveRecord{Kind: recordResult, Result: r}); werr != nil {
+				return false
+			}
+			stats.Results++
+			return true
+		})
+		if err == nil {
+			err = werr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	log.Infof("export tasks: %d, results: %d", stats.Tasks, stats.Results)
+	return stats, nil
+}
+
+// ExportFile exports tasks into archive file, file is replaced only if export succeeds.
+func (m *Manager) ExportFile(file string, results bool) (*ArchiveStats, error) {
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := m.Export(f, results)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return stats, os.Rename(tmp, file)
+}
+
+// Import reads archive written by Export. Task whose uuid is used is handled by
+// conflict policy, scheduled tasks are added into time wheel again and running
+// tasks are imported as failed.
+func (m *Manager) Import(r io.Reader, conflict string) (*ArchiveStats, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	switch conflict {
+	case "":
+		conflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictRenew:
+	default:
+		return nil, ErrInvalidConflict
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(zr)
+
+	var header ArchiveHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version < 1 || header.Version > ArchiveVersion {
+		return nil, fmt.Errorf("%v: %d", ErrArchiveVersion, header.Version)
+	}
+
+	var (
+		stats   = &ArchiveStats{}
+		renewed = make(map[int64]int64)
+		skipped = make(map[int64]bool)
+	)
+	for {
+		var rec archiveRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return stats, err
+		}
+
+		switch {
+		case rec.Kind == recordTask && rec.Job != nil:
+			if err := m.importJob(rec.Job, conflict, renewed, skipped, stats); err != nil {
+				return stats, err
+			}
+		case rec.Kind == recordResult && rec.Result != nil:
+			if skipped[rec.Result.ID] {
+				continue
+			}
+			if id, ok := renewed[rec.Result.ID]; ok {
+				rec.Result.ID = id
+			}
+			if err := m.importResult(rec.Result); err != nil {
+				return stats, err
+			}
+			stats.Results++
+		default:
+			log.Warnf("unknown archive record %s", rec.Kind)
+		}
+	}
+
+	log.Infof("import tasks: %d, results: %d, skipped: %d, renewed: %d",
+		stats.Tasks, stats.Results, stats.Skipped, stats.Renewed)
+	return stats, nil
+}
+
+// archivePath returns path of archive file in archive directory of data
+// directory, relative file is in archive directory.
+func (m *Manager) archivePath(file string) (string, error) {
+	dir := filepath.Join(m.root, DefaultArchiveDir)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	file = filepath.Clean(file)
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrArchivePath
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// ImportFile imports tasks from archive file.
+func (m *Manager) ImportFile(file, conflict string) (*ArchiveStats, error) {
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return m.Import(f, conflict)
+}
+
+// importJob writes archived job and schedules it by its state.
+func (m *Manager) importJob(job *cmn.Job, conflict string, renewed map[int64]int64,
+	skipped map[int64]bool, stats *ArchiveStats) error {
+	id := job.UUID.Int64()
+
+	b := m.dbTask.NewBatch()
+	existing, err := m.getJob(job.UUID.Uint64())
+	if err == nil {
+		switch conflict {
+		case ConflictSkip:
+			skipped[id] = true
+			stats.Skipped++
+			return nil
+		case ConflictOverwrite:
+			// task being executed is changed by its execution.
+			if m.isInflight(id) {
+				log.Warnf("task %d is being executed, it is not overwritten", id)
+				skipped[id] = true
+				stats.Skipped++
+				return nil
+			}
+			if err := m.deleteJob(b, existing); err != nil {
+				return err
+			}
+			m.tw.Delete(id)
+			if err := m.deleteResults(existing.UUID.Uint64()); err != nil {
+				return err
+			}
+		case ConflictRenew:
+			newID, err := m.genID.NextId()
+			if err != nil {
+				return err
+			}
+			renewed[id] = newID
+			job.UUID = cmn.EncodeItemID(uint64(newID))
+			stats.Renewed++
+		}
+	} else if err != store.ErrNotFound {
+		return err
+	}
+
+	if job.State == cmn.JobStateRunning || job.State == cmn.JobStateRetrying {
+		if err := job.Transit(cmn.JobStateFailed, time.Now().Unix()); err != nil {
+			return err
+		}
+	}
+	if err := m.putJob(b, job); err != nil {
+		return err
+	}
+	if err := b.Write(); err != nil {
+		return err
+	}
+
+	if job.Type == cmn.JobTypeFile && (job.State == cmn.JobStateScheduled || job.State == cmn.JobStatePaused) {
+		if err := m.writeScript(job); err != nil {
+			log.Errorf("write script file error, %d: %v", job.UUID.Int64(), err)
+		}
+	}
+	if job.State == cmn.JobStateScheduled {
+		m.schedule(job)
+	}
+	stats.Tasks++
+	return nil
+}
+
+// importResult writes archived result run, it is last result of task if it is
+// the latest run.
+func (m *Manager) importResult(r *cmn.Result) error {
+	jsonBytes, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := m.dbHistory.Put(store.RunKey(uint64(r.ID), r.Run), jsonBytes); err != nil {
+		return err
+	}
+
+	key := cmn.EncodeItemID(uint64(r.ID)).Bytes()
+	if lastBytes, err := m.dbResult.Get(key); err == nil {
+		var last cmn.Result
+		if err := json.Unmarshal(lastBytes, &last); err == nil && last.Run > r.Run {
+			return nil
+		}
+	}
+	return m.dbResult.Put(key, jsonBytes)
+}
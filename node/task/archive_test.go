@@ -0,0 +1,201 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	snowflake "github.com/zheng-ji/goSnowFlake"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// newArchiveManager returns test manager which generates uuid and writes scripts.
+func newArchiveManager(t *testing.T) *Manager {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	genID, err := snowflake.NewIdWorker(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.genID = genID
+	return m
+}
+
+// putArchiveTasks writes tasks: 1 scheduled, 2 succeeded with two runs, 3 running.
+func putArchiveTasks(t *testing.T, m *Manager) {
+	states := []cmn.JobState{cmn.JobStateScheduled, cmn.JobStateSucceeded, cmn.JobStateRunning}
+	for i, state := range states {
+		putTestJob(t, m, &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(uint64(i + 1)),
+			Interval: 60, AddTime: 1000, State: state})
+	}
+	putTestRuns(t, m, 2, 1010, 1020)
+}
+
+func exportTasks(t *testing.T, m *Manager, results bool) *bytes.Buffer {
+	var buf bytes.Buffer
+	stats, err := m.Export(&buf, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Tasks != 3 || (results && stats.Results != 2) {
+		t.Fatalf("export: %+v", stats)
+	}
+	return &buf
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := newArchiveManager(t)
+	putArchiveTasks(t, src)
+	buf := exportTasks(t, src, true)
+
+	dst := newArchiveManager(t)
+	stats, err := dst.Import(buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != (ArchiveStats{Tasks: 3, Results: 2}) {
+		t.Fatalf("import: %+v", stats)
+	}
+
+	want := map[uint64]cmn.JobState{1: cmn.JobStateScheduled, 2: cmn.JobStateSucceeded, 3: cmn.JobStateFailed}
+	for id, state := range want {
+		job, err := dst.getJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != state {
+			t.Errorf("task %d: %s, want %s", id, job.State, state)
+		}
+	}
+	if !dst.tw.Check(1) || dst.tw.Check(2) {
+		t.Error("scheduled task is not in time wheel")
+	}
+	r, err := dst.Result(2)
+	if err != nil || r.Run != 2 || r.EndTime != 1020 {
+		t.Fatalf("last result: %#v, %v", r, err)
+	}
+	if n := len(dst.history(t, 2)); n != 2 {
+		t.Errorf("runs: %d", n)
+	}
+}
+
+func TestArchiveConflict(t *testing.T) {
+	src := newArchiveManager(t)
+	putArchiveTasks(t, src)
+
+	tests := []struct {
+		conflict string
+		stats    ArchiveStats
+		runs     int // runs of task 2 after import
+		last     int64
+	}{
+		{ConflictSkip, ArchiveStats{Tasks: 2, Skipped: 1}, 3, 30},
+		{ConflictOverwrite, ArchiveStats{Tasks: 3, Results: 2}, 2, 1020},
+		{ConflictRenew, ArchiveStats{Tasks: 3, Results: 2, Renewed: 1}, 3, 30},
+	}
+	for _, tt := range tests {
+		dst := newArchiveManager(t)
+		putTestJob(t, dst, &cmn.Job{Name: "other", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2),
+			State: cmn.JobStateFailed})
+		putTestRuns(t, dst, 2, 10, 20, 30)
+
+		stats, err := dst.Import(exportTasks(t, src, true), tt.conflict)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.conflict, err)
+		}
+		if *stats != tt.stats {
+			t.Errorf("%s: stats %+v, want %+v", tt.conflict, stats, tt.stats)
+		}
+		if n := len(dst.history(t, 2)); n != tt.runs {
+			t.Errorf("%s: runs %d, want %d", tt.conflict, n, tt.runs)
+		}
+		if r, err := dst.Result(2); err != nil || r.EndTime != tt.last {
+			t.Errorf("%s: last result %#v, %v", tt.conflict, r, err)
+		}
+
+		page, err := dst.ListTasks(&TaskQuery{Name: "dev"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.conflict == ConflictRenew {
+			var renewed uint64
+			for _, info := range page.Tasks {
+				if id := info.Job.UUID.Uint64(); id > 3 {
+					renewed = id
+				}
+			}
+			if n := len(dst.history(t, renewed)); renewed == 0 || n != 2 {
+				t.Errorf("renewed task %d: runs %d", renewed, n)
+			}
+		}
+	}
+}
+
+func TestArchiveOverwriteInflight(t *testing.T) {
+	src := newArchiveManager(t)
+	putArchiveTasks(t, src)
+
+	dst := newArchiveManager(t)
+	putTestJob(t, dst, &cmn.Job{Name: "other", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2),
+		State: cmn.JobStateRunning})
+	dst.inflight[2] = struct{}{}
+
+	stats, err := dst.Import(exportTasks(t, src, true), ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Skipped != 1 {
+		t.Errorf("stats %+v, want task being executed skipped", stats)
+	}
+	if job, err := dst.getJob(2); err != nil || job.Name != "other" || job.State != cmn.JobStateRunning {
+		t.Fatalf("task being executed is overwritten: %#v, %v", job, err)
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	m := newTestManager()
+	m.root = t.TempDir()
+	dir := filepath.Join(m.root, DefaultArchiveDir)
+
+	tests := []struct {
+		file string
+		path string
+	}{
+		{"task.backup", filepath.Join(dir, "task.backup")},
+		{"a/../task.backup", filepath.Join(dir, "task.backup")},
+		{filepath.Join(dir, "b", "task.backup"), filepath.Join(dir, "b", "task.backup")},
+		{"../task.backup", ""},
+		{"..", ""},
+		{".", ""},
+		{"/tmp/task.backup", ""},
+		{filepath.Join(m.root, "task.db"), ""},
+	}
+	for _, tt := range tests {
+		path, err := m.archivePath(tt.file)
+		if tt.path == "" {
+			if err != ErrArchivePath {
+				t.Errorf("%s: path %s, error %v", tt.file, path, err)
+			}
+			continue
+		}
+		if err != nil || path != tt.path {
+			t.Errorf("%s: path %s, %v, want %s", tt.file, path, err, tt.path)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"airman.com/airfk/pkg/common/hexutil"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// isoDuration matches ISO-8601 duration of weeks, days, hours, minutes and seconds.
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ExtraArg is extra of job in arguments. It is hex string with 0x prefix, plain
+// string, or JSON object or array which is kept as compact JSON. A string which
+// is valid hex with 0x prefix, like "0xdeadbeef", is always decoded as hex, so
+// such text has to be sent hex encoded. Hex is kept for compatibility with
+// clients which send extra as hexutil.Bytes.
+type ExtraArg []byte
+
+// UnmarshalJSON parses extra in any of its forms.
+func (e *ExtraArg) UnmarshalJSON(input []byte) error {
+	input = bytes.TrimSpace(input)
+	if len(input) == 0 || input[0] == 'n' {
+		return cmn.NewInvalidError("extra", "no extra field")
+	}
+
+	if input[0] == '"' {
+		var s string
+		if err := json.Unmarshal(input, &s); err != nil {
+			return cmn.NewInvalidError("extra", err.Error())
+		}
+		if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+			if b, err := hexutil.Decode(s); err == nil {
+				*e = b
+				return nil
+			}
+		}
+		*e = []byte(s)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, input); err != nil {
+		return cmn.NewInvalidError("extra", err.Error())
+	}
+	*e = buf.Bytes()
+	return nil
+}
+
+// MarshalJSON encodes extra as hex string.
+func (e ExtraArg) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hexutil.Bytes(e))
+}
+
+// DatetimeArg is fire time of job in arguments. It is unix seconds, or RFC3339
+// string with time zone like "2019-06-23T10:00:00+08:00".
+type DatetimeArg int64
+
+// UnmarshalJSON parses datetime in any of its forms.
+func (d *DatetimeArg) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		var s string
+		if err := json.Unmarshal(input, &s); err != nil {
+			return cmn.NewInvalidError("datetime", err.Error())
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return cmn.NewInvalidError("datetime", err.Error())
+		}
+		*d = DatetimeArg(t.Unix())
+		return nil
+	}
+
+	var n int64
+	if err := json.Unmarshal(input, &n); err != nil {
+		return cmn.NewInvalidError("datetime", err.Error())
+	}
+	*d = DatetimeArg(n)
+	return nil
+}
+
+// IntervalArg is delay of job in seconds in arguments. It is integer seconds,
+// Go duration like "5m", or ISO-8601 duration like "PT1H". Fraction of second
+// is rounded up.
+type IntervalArg int
+
+// UnmarshalJSON parses interval in any of its forms.
+func (i *IntervalArg) UnmarshalJSON(input []byte) error {
+	if len(input) > 0 && input[0] == '"' {
+		var s string
+		if err := json.Unmarshal(input, &s); err != nil {
+			return cmn.NewInvalidError("interval", err.Error())
+		}
+		d, err := parseDuration(s)
+		if err != nil {
+			return cmn.NewInvalidError("interval", err.Error())
+		}
+		if d < 0 || d > math.MaxInt32*time.Second {
+			return cmn.NewInvalidError("interval", fmt.Sprintf("interval %q out of range", s))
+		}
+		*i = IntervalArg(math.Ceil(d.Seconds()))
+		return nil
+	}
+
+	var n int
+	if err := json.Unmarshal(input, &n); err != nil {
+		return cmn.NewInvalidError("interval", err.Error())
+	}
+	*i = IntervalArg(n)
+	return nil
+}
+
+// parseDuration parses Go or ISO-8601 duration.
+func parseDuration(s string) (time.Duration, error) {
+	if !strings.HasPrefix(s, "P") {
+		return time.ParseDuration(s)
+	}
+
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid ISO-8601 duration %q", s)
+	}
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(v * float64(unit))
+	}
+	return d, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJobArgsInputs(t *testing.T) {
+	tests := []struct {
+		input    string
+		extra    string
+		datetime int64
+		interval int
+	}{
+		{`{"extra":"0x756e616d65202d61","datetime":1893524645,"interval":5}`, "uname -a", 1893524645, 5},
+		{`{"extra":"uname -a","interval":"5m"}`, "uname -a", 0, 300},
+		{`{"extra":{"cmd": "ls"},"interval":"PT1H30M"}`, `{"cmd":"ls"}`, 0, 5400},
+		{`{"extra":"0xzz","interval":"P1DT0.5S"}`, "0xzz", 0, 86401},
+		{`{"extra":"","datetime":"2030-01-02T03:04:05+08:00","interval":"P1W"}`, "", 1893524645, 604800},
+	}
+	for _, tt := range tests {
+		var args JobArgs
+		if err := json.Unmarshal([]byte(tt.input), &args); err != nil {
+			t.Fatalf("%s: %v", tt.input, err)
+		}
+		if string(*args.Extra) != tt.extra || int64(args.Datetime) != tt.datetime || int(args.Interval) != tt.interval {
+			t.Fatalf("%s: extra %q, datetime %d, interval %d", tt.input, *args.Extra, args.Datetime, args.Interval)
+		}
+	}
+
+	// string which is valid hex is decoded, hex of the text keeps it as text.
+	for input, extra := range map[string]string{
+		`{"extra":"0xdeadbeef"}`:             "\xde\xad\xbe\xef",
+		`{"extra":"0X6869"}`:                 "hi",
+		`{"extra":"0x"}`:                     "",
+		`{"extra":"0x646561646265656"}`:      "0x646561646265656",
+		`{"extra":"0x3078646561646265656"}`:  "0x3078646561646265656",
+		`{"extra":"0x30786465616462656566"}`: "0xdeadbeef",
+	} {
+		var args JobArgs
+		if err := json.Unmarshal([]byte(input), &args); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if string(*args.Extra) != extra {
+			t.Errorf("%s: extra %q, want %q", input, *args.Extra, extra)
+		}
+	}
+
+	for _, input := range []string{
+		`{"datetime":"2030-01-02T03:04:05"}`,
+		`{"interval":"PT"}`,
+		`{"interval":"P1M"}`,
+		`{"interval":"-5m"}`,
+		`{"interval":"soon"}`,
+	} {
+		var args JobArgs
+		if err := json.Unmarshal([]byte(input), &args); err == nil {
+			t.Fatalf("%s: no error", input)
+		}
+	}
+}
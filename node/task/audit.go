@@ -0,0 +1,239 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 1000
+	MaxPendingAudit   = 1000 // max entries buffered while audit log is closed
+)
+
+// callers which are not remote.
+const (
+	CallerLocal   = "local"   // in-process call of library
+	CallerWatcher = "watcher" // module directory watcher
+)
+
+// AuditEntry is a mutating call recorded in audit log.
+type AuditEntry struct {
+	Seq    uint64          `json:"seq"`
+	Time   int64           `json:"time"`
+	Node   string          `json:"node"`
+	Caller string          `json:"caller"`
+	Agent  string          `json:"agent,omitempty"`
+	Action string          `json:"action"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result string          `json:"result"`
+}
+
+// AuditQuery is condition of listing audit log, zero value of field means no filter.
+type AuditQuery struct {
+	Start  uint64 // first sequence of page, inclusive
+	Limit  int    // max number of entries
+	From   int64  // lower bound of time in unix seconds, inclusive
+	To     int64  // upper bound of time in unix seconds, inclusive
+	Action string // action of entry
+	Caller string // caller of entry
+}
+
+// AuditPage is a page of audit log.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Next    uint64       `json:"next"` // first sequence of next page, 0 if no more entries
+}
+
+// callerFromContext returns remote address and user agent of RPC request, it is
+// local if the call is not from RPC.
+func callerFromContext(ctx context.Context) (string, string) {
+	caller, agent := cmn.CallerFromContext(ctx)
+	if caller == "" {
+		caller = CallerLocal
+	}
+	return caller, agent
+}
+
+// loadAuditSeq loads last sequence of audit log.
+func (m *Manager) loadAuditSeq() error {
+	seq, err := m.loadSeq(store.AuditSeqKey)
+	if err != nil {
+		return err
+	}
+	m.auditSeq = seq
+	return nil
+}
+
+// loadSeq loads last sequence of the given key in meta store.
+func (m *Manager) loadSeq(key []byte) (uint64, error) {
+	value, err := m.dbMeta.Get(key)
+	if err == store.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("invalid sequence of %s: %x", key, value)
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// Audit appends a mutating call with its parameters and outcome to audit log.
+// Entry and last sequence are written in one batch, entries are never changed.
+func (m *Manager) Audit(ctx context.Context, action string, params interface{}, err error) {
+	caller, agent := callerFromContext(ctx)
+	m.audit(caller, agent, action, params, err)
+}
+
+// audit appends a call of the given caller to audit log. Entry of call before
+// audit log is opened by Start is buffered, it is written when log is opened.
+func (m *Manager) audit(caller, agent, action string, params interface{}, err error) {
+	entry := &AuditEntry{
+		Time:   time.Now().Unix(),
+		Node:   m.backend.NodeID(),
+		Caller: caller,
+		Agent:  agent,
+		Action: action,
+		Result: cmn.ToMsg(err),
+	}
+	if params != nil {
+		paramBytes, err := json.Marshal(params)
+		if err != nil {
+			log.Errorf("json marshal audit params error, %s, %v", action, err)
+		} else {
+			entry.Params = paramBytes
+		}
+	}
+
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+
+	if !m.auditOpen {
+		if len(m.auditPending) == MaxPendingAudit {
+			log.Warnf("audit log is not opened, entry is dropped, %s", m.auditPending[0].Action)
+			m.auditPending = m.auditPending[1:]
+		}
+		m.auditPending = append(m.auditPending, entry)
+		return
+	}
+	m.appendAudit(entry)
+}
+
+// appendAudit numbers entry and writes it, m.auditMu should be held.
+func (m *Manager) appendAudit(entry *AuditEntry) {
+	entry.Seq = m.auditSeq + 1
+	if err := m.putAudit(entry); err != nil {
+		log.Errorf("write audit log error, %#v, %v", entry, err)
+		return
+	}
+	m.auditSeq = entry.Seq
+}
+
+// openAudit writes buffered entries, entries are written directly after it.
+func (m *Manager) openAudit() {
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+
+	for _, entry := range m.auditPending {
+		m.appendAudit(entry)
+	}
+	m.auditPending = nil
+	m.auditOpen = true
+}
+
+// closeAudit buffers entries until audit log is opened again.
+func (m *Manager) closeAudit() {
+	m.auditMu.Lock()
+	defer m.auditMu.Unlock()
+
+	m.auditOpen = false
+}
+
+// putAudit writes audit entry and last sequence.
+func (m *Manager) putAudit(entry *AuditEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b := m.dbAudit.NewBatch()
+	if err := b.Put(m.dbAudit, store.AuditKey(entry.Seq), entryBytes); err != nil {
+		return err
+	}
+	if err := b.Put(m.dbMeta, store.AuditSeqKey, store.SeqKey(entry.Seq)); err != nil {
+		return err
+	}
+	return b.Write()
+}
+
+// AuditLog lists audit log ordered by sequence.
+func (m *Manager) AuditLog(q *AuditQuery) (*AuditPage, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	if q == nil {
+		q = &AuditQuery{}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	} else if limit > MaxAuditLimit {
+		limit = MaxAuditLimit
+	}
+
+	var start []byte
+	if q.Start > 0 {
+		start = store.AuditKey(q.Start)
+	}
+
+	page := &AuditPage{Entries: make([]AuditEntry, 0, limit)}
+	err := m.dbAudit.Iterate(nil, start, func(key, value []byte) bool {
+		var entry AuditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			log.Errorf("json unmarshal audit entry error, %x, %v", key, err)
+			return true
+		}
+		if q.To > 0 && entry.Time > q.To {
+			return false
+		}
+		if entry.Time < q.From ||
+			(q.Action != "" && entry.Action != q.Action) ||
+			(q.Caller != "" && entry.Caller != q.Caller) {
+			return true
+		}
+		if len(page.Entries) == limit {
+			page.Next = entry.Seq
+			return false
+		}
+		page.Entries = append(page.Entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+func auditActions(t *testing.T, m *Manager) []string {
+	page, err := m.AuditLog(&AuditQuery{Limit: MaxAuditLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, len(page.Entries))
+	for i, entry := range page.Entries {
+		if entry.Seq != uint64(i+1) {
+			t.Errorf("entry %d: seq %d, want %d", i, entry.Seq, i+1)
+		}
+		actions[i] = entry.Action
+	}
+	return actions
+}
+
+func TestAuditBeforeOpen(t *testing.T) {
+	m := newTestManager()
+	m.Audit(context.Background(), "register", nil, nil)
+	m.Audit(context.Background(), "add", map[string]int{"id": 1}, errors.New("failed"))
+	if actions := auditActions(t, m); len(actions) != 0 {
+		t.Fatalf("entries written before open: %v", actions)
+	}
+
+	m.openAudit()
+	m.Audit(context.Background(), "delete", nil, nil)
+	actions := auditActions(t, m)
+	if fmt.Sprint(actions) != "[register add delete]" {
+		t.Fatalf("actions %v, want [register add delete]", actions)
+	}
+	if seq, err := m.loadSeq(store.AuditSeqKey); err != nil || seq != 3 {
+		t.Fatalf("last seq %d, %v, want 3", seq, err)
+	}
+
+	m.closeAudit()
+	m.Audit(context.Background(), "stop", nil, nil)
+	if actions := auditActions(t, m); len(actions) != 3 {
+		t.Fatalf("entries written after close: %v", actions)
+	}
+	m.openAudit()
+	if actions := auditActions(t, m); len(actions) != 4 || actions[3] != "stop" {
+		t.Fatalf("actions %v, want stop flushed", actions)
+	}
+}
+
+func TestAuditPendingLimit(t *testing.T) {
+	m := newTestManager()
+	for i := 0; i < MaxPendingAudit+2; i++ {
+		m.Audit(context.Background(), fmt.Sprint(i), nil, nil)
+	}
+	m.openAudit()
+	actions := auditActions(t, m)
+	if len(actions) != MaxPendingAudit {
+		t.Fatalf("%d entries, want %d", len(actions), MaxPendingAudit)
+	}
+	if actions[0] != "2" || actions[len(actions)-1] != fmt.Sprint(MaxPendingAudit+1) {
+		t.Fatalf("oldest entries are not dropped: first %s, last %s", actions[0], actions[len(actions)-1])
+	}
+}
+
+func TestAuditCaller(t *testing.T) {
+	m := newTestManager()
+	m.openAudit()
+
+	m.Audit(cmn.WithCaller(context.Background(), "10.0.0.1:3456", "curl/7.58"), "add", nil, nil)
+	m.Audit(context.WithValue(context.Background(), "remote", "10.0.0.2:3456"), "add", nil, nil)
+	m.Audit(context.Background(), "add", nil, nil)
+
+	page, err := m.AuditLog(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][2]string{
+		{"10.0.0.1:3456", "curl/7.58"},
+		{CallerLocal, ""},
+		{CallerLocal, ""},
+	}
+	if len(page.Entries) != len(want) {
+		t.Fatalf("%d entries, want %d", len(page.Entries), len(want))
+	}
+	for i, entry := range page.Entries {
+		if entry.Caller != want[i][0] || entry.Agent != want[i][1] {
+			t.Errorf("entry %d: caller %q agent %q, want %q %q", i, entry.Caller, entry.Agent, want[i][0], want[i][1])
+		}
+	}
+
+	page, err = m.AuditLog(&AuditQuery{Caller: "10.0.0.1:3456"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Seq != 1 {
+		t.Fatalf("caller query returned %+v", page.Entries)
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/metrics"
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultCompactInterval = 10 * time.Minute
+
+	compactBatchSize = 256 // max keys deleted in a batch
+)
+
+// CompactStats is what is removed by a compaction.
+type CompactStats struct {
+	Tasks      int   `json:"tasks"`
+	Results    int   `json:"results"`
+	Scripts    int   `json:"scripts"`
+	Events     int   `json:"events"`
+	Deliveries int   `json:"deliveries"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// runEntry is a result run in history store.
+type runEntry struct {
+	key   []byte
+	id    uint64
+	begin int64
+	end   int64
+	size  int64
+}
+
+// compactLoop runs compaction periodically.
+func (m *Manager) compactLoop() {
+	ticker := time.NewTicker(m.opts.Retention.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := m.Compact(); err != nil {
+				log.Errorf("compact error, %v", err)
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// Compact deletes expired tasks, results, events and orphaned script files by
+// retention. Stores are scanned without lock of manager, which is held only
+// while a batch of tasks or scripts is deleted.
+func (m *Manager) Compact() (*CompactStats, error) {
+	m.compactMu.Lock()
+	defer m.compactMu.Unlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	stats := &CompactStats{}
+	r := m.opts.Retention
+	now := time.Now()
+
+	if r.MaxAge > 0 {
+		if err := m.compactTasks(now.Add(-r.MaxAge).Unix(), stats); err != nil {
+			return stats, err
+		}
+	}
+	if r.MaxAge > 0 || r.MaxRuns > 0 || r.MaxSize > 0 {
+		if err := m.compactResults(r, now, stats); err != nil {
+			return stats, err
+		}
+	}
+	if err := m.compactScripts(stats); err != nil {
+		return stats, err
+	}
+	if r.EventMaxAge > 0 {
+		if err := m.compactEvents(now.Add(-r.EventMaxAge).Unix(), stats); err != nil {
+			return stats, err
+		}
+	}
+	if r.DeliveryMaxAge > 0 {
+		if err := m.compactDeliveries(now.Add(-r.DeliveryMaxAge).Unix(), stats); err != nil {
+			return stats, err
+		}
+	}
+
+	metrics.CompactTaskCounter.Inc(int64(stats.Tasks))
+	metrics.CompactResultCounter.Inc(int64(stats.Results))
+	metrics.CompactScriptCounter.Inc(int64(stats.Scripts))
+	metrics.CompactEventCounter.Inc(int64(stats.Events))
+	metrics.CompactHookCounter.Inc(int64(stats.Deliveries))
+	metrics.CompactBytesCounter.Inc(stats.Bytes)
+
+	log.Infof("compact tasks: %d, results: %d, scripts: %d, events: %d, deliveries: %d, bytes: %d",
+		stats.Tasks, stats.Results, stats.Scripts, stats.Events, stats.Deliveries, stats.Bytes)
+	return stats, nil
+}
+
+// deleteKeys deletes keys from store in batches of compactBatchSize.
+func deleteKeys(s *store.Store, keys [][]byte) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > compactBatchSize {
+			n = compactBatchSize
+		}
+		b := s.NewBatch()
+		for _, key := range keys[:n] {
+			if err := b.Delete(s, key); err != nil {
+				return err
+			}
+		}
+		if err := b.Write(); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+// compactTasks deletes tasks which are finished before deadline, with their results.
+func (m *Manager) compactTasks(deadline int64, stats *CompactStats) error {
+	var ids []uint64
+	for _, state := range cmn.JobStates {
+		if !state.IsTerminal() {
+			continue
+		}
+		stateIDs, err := m.jobsInState(state)
+		if err != nil {
+			return err
+		}
+		for _, id := range stateIDs {
+			job, err := m.getJob(id)
+			if err != nil {
+				log.Errorf("get job error, %d, %v", id, err)
+				continue
+			}
+			if job.StateTime < deadline {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	for len(ids) > 0 {
+		n := len(ids)
+		if n > compactBatchSize {
+			n = compactBatchSize
+		}
+		jobs, err := m.deleteFinishedJobs(ids[:n], deadline)
+		if err != nil {
+			return err
+		}
+		for _, job := range jobs {
+			if err := m.deleteResults(job.UUID.Uint64()); err != nil {
+				return err
+			}
+			m.publishTask(cmn.LifecycleDeleted, job, 0, nil)
+			stats.Tasks++
+		}
+		ids = ids[n:]
+	}
+
+	// last results of deleted tasks
+	var keys [][]byte
+	err := m.dbResult.Iterate(nil, nil, func(key, value []byte) bool {
+		var result cmn.Result
+		if err := json.Unmarshal(value, &result); err != nil || result.EndTime >= deadline {
+			return true
+		}
+		if ok, err := m.dbTask.Has(key); err == nil && !ok {
+			keys = append(keys, key)
+			stats.Bytes += int64(len(key) + len(value))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if err := deleteKeys(m.dbResult, keys); err != nil {
+		return err
+	}
+	stats.Results += len(keys)
+	return nil
+}
+
+// deleteFinishedJobs deletes a batch of jobs under lock, jobs which are
+// scheduled or changed since they are scanned are skipped.
+func (m *Manager) deleteFinishedJobs(ids []uint64, deadline int64) ([]*cmn.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []*cmn.Job
+	b := m.dbTask.NewBatch()
+	for _, id := range ids {
+		if m.tw.Check(int64(id)) || m.isInflight(int64(id)) {
+			continue
+		}
+		job, err := m.getJob(id)
+		if err != nil || !job.State.IsTerminal() || job.StateTime >= deadline {
+			continue
+		}
+		if err := m.deleteJob(b, job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := b.Write(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// compactResults deletes result runs by max age, max runs per task and max total size.
+func (m *Manager) compactResults(r Retention, now time.Time, stats *CompactStats) error {
+	var (
+		entries []*runEntry
+		deletes []*runEntry
+	)
+	err := m.dbHistory.Iterate(nil, nil, func(key, value []byte) bool {
+		var result cmn.Result
+		if err := json.Unmarshal(value, &result); err != nil {
+			log.Errorf("json unmarshal result error, %x, %v", key, err)
+			return true
+		}
+		entries = append(entries, &runEntry{
+			key:   key,
+			id:    binary.BigEndian.Uint64(key[:8]),
+			begin: result.BeginTime,
+			end:   result.EndTime,
+			size:  int64(len(key) + len(value)),
+		})
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	// max age and max runs, entries are ordered by task and run.
+	kept := make([]*runEntry, 0, len(entries))
+	for i := 0; i < len(entries); {
+		j := i
+		for j < len(entries) && entries[j].id == entries[i].id {
+			j++
+		}
+		runs := entries[i:j]
+		if r.MaxRuns > 0 && len(runs) > r.MaxRuns {
+			deletes = append(deletes, runs[:len(runs)-r.MaxRuns]...)
+			runs = runs[len(runs)-r.MaxRuns:]
+		}
+		for _, e := range runs {
+			if r.MaxAge > 0 && e.end < now.Add(-r.MaxAge).Unix() {
+				deletes = append(deletes, e)
+			} else {
+				kept = append(kept, e)
+			}
+		}
+		i = j
+	}
+
+	// max total size, the oldest runs are deleted first.
+	if r.MaxSize > 0 {
+		var total int64
+		for _, e := range kept {
+			total += e.size
+		}
+		sort.SliceStable(kept, func(i, j int) bool {
+			return kept[i].begin < kept[j].begin
+		})
+		for _, e := range kept {
+			if total <= r.MaxSize {
+				break
+			}
+			deletes = append(deletes, e)
+			total -= e.size
+		}
+	}
+
+	keys := make([][]byte, len(deletes))
+	for i, e := range deletes {
+		keys[i] = e.key
+		stats.Bytes += e.size
+	}
+	if err := deleteKeys(m.dbHistory, keys); err != nil {
+		return err
+	}
+	stats.Results += len(keys)
+	return nil
+}
+
+// compactScripts removes script files of tasks which are finished or deleted,
+// scripts of scheduled, paused and running tasks are kept.
+func (m *Manager) compactScripts(stats *CompactStats) error {
+	files, err := ioutil.ReadDir(m.cmdRoot)
+	if err != nil {
+		return err
+	}
+
+	var scripts []os.FileInfo
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".sh") {
+			scripts = append(scripts, f)
+		}
+	}
+	for len(scripts) > 0 {
+		n := len(scripts)
+		if n > compactBatchSize {
+			n = compactBatchSize
+		}
+		m.removeScripts(scripts[:n], stats)
+		scripts = scripts[n:]
+	}
+	return nil
+}
+
+// removeScripts removes a batch of script files under lock, so scripts of tasks
+// being added are not removed.
+func (m *Manager) removeScripts(files []os.FileInfo, stats *CompactStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range files {
+		id, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), ".sh"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if m.tw.Check(id) || m.isInflight(id) {
+			continue
+		}
+		if job, err := m.getJob(uint64(id)); err == nil && !job.State.IsTerminal() {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.cmdRoot, f.Name())); err != nil {
+			log.Errorf("remove script file error, %s: %v", f.Name(), err)
+			continue
+		}
+		stats.Scripts++
+		stats.Bytes += f.Size()
+	}
+}
+
+// isInflight returns task is triggered and not finished.
+func (m *Manager) isInflight(id int64) bool {
+	_, ok := m.inflight[id]
+	return ok
+}
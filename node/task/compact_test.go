@@ -0,0 +1,214 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// putTestRuns saves runs of task which end at the given times.
+func putTestRuns(t *testing.T, m *Manager, id int64, ends ...int64) {
+	for _, end := range ends {
+		r := cmn.NewResultWithEnd(id, end-1, end, "success", []byte("output"))
+		if err := m.saveResult(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompactMaxRuns(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	m.opts.Retention = Retention{MaxRuns: 2}
+	now := time.Now().Unix()
+	putTestRuns(t, m, 1, now-5, now-4, now-3, now-2, now-1)
+	putTestRuns(t, m, 2, now-1)
+
+	stats, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Results != 3 {
+		t.Errorf("compacted results: %d", stats.Results)
+	}
+	keys := m.history(t, 1)
+	if len(keys) != 2 || string(keys[0]) != string(store.RunKey(1, 4)) {
+		t.Errorf("runs of task 1: %x", keys)
+	}
+	if n := len(m.history(t, 2)); n != 1 {
+		t.Errorf("runs of task 2: %d", n)
+	}
+}
+
+func TestCompactMaxSize(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	now := time.Now().Unix()
+	putTestRuns(t, m, 1, now-30, now-10)
+	putTestRuns(t, m, 2, now-20)
+
+	// the oldest runs are deleted until two runs are left.
+	var size int64
+	err := m.dbHistory.Iterate(nil, nil, func(key, value []byte) bool {
+		size = int64(len(key) + len(value))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.opts.Retention = Retention{MaxSize: 2 * size}
+
+	stats, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Results != 1 || stats.Bytes != size {
+		t.Errorf("stats: %+v", stats)
+	}
+	if keys := m.history(t, 1); len(keys) != 1 || string(keys[0]) != string(store.RunKey(1, 2)) {
+		t.Errorf("runs of task 1: %x", keys)
+	}
+	if n := len(m.history(t, 2)); n != 1 {
+		t.Errorf("runs of task 2: %d", n)
+	}
+}
+
+func TestCompactMaxAge(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	m.opts.Retention = Retention{MaxAge: time.Hour}
+	now := time.Now().Unix()
+
+	// 1: finished long ago, 2: finished recently, 3: scheduled long ago
+	old := &cmn.Job{Name: "old", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1),
+		State: cmn.JobStateSucceeded, StateTime: now - 7200}
+	recent := &cmn.Job{Name: "recent", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2),
+		State: cmn.JobStateFailed, StateTime: now - 60}
+	scheduled := &cmn.Job{Name: "scheduled", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(3),
+		State: cmn.JobStateScheduled, StateTime: now - 7200}
+	for _, job := range []*cmn.Job{old, recent, scheduled} {
+		putTestJob(t, m, job)
+	}
+	putTestRuns(t, m, 1, now-7300, now-7200)
+	putTestRuns(t, m, 2, now-7200, now-60)
+	// last result of task which is deleted
+	putTestRuns(t, m, 9, now-7200)
+	if err := m.dbTask.Delete(cmn.EncodeItemID(9).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Tasks != 1 {
+		t.Errorf("compacted tasks: %d", stats.Tasks)
+	}
+	if _, err := m.getJob(1); err != store.ErrNotFound {
+		t.Errorf("old task: %v", err)
+	}
+	if _, err := m.Result(1); err != store.ErrNotFound {
+		t.Errorf("result of old task: %v", err)
+	}
+	if n := len(m.history(t, 1)); n != 0 {
+		t.Errorf("runs of old task: %d", n)
+	}
+	for _, id := range []uint64{2, 3} {
+		if _, err := m.getJob(id); err != nil {
+			t.Errorf("task %d: %v", id, err)
+		}
+	}
+	if n := len(m.history(t, 2)); n != 1 {
+		t.Errorf("runs of recent task: %d", n)
+	}
+	if _, err := m.Result(9); err != store.ErrNotFound {
+		t.Errorf("orphaned result: %v", err)
+	}
+}
+
+func TestCompactScripts(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+
+	// 1: paused, 2: succeeded, 3: deleted, 4: in time wheel
+	states := map[uint64]cmn.JobState{1: cmn.JobStatePaused, 2: cmn.JobStateSucceeded}
+	for id, state := range states {
+		putTestJob(t, m, &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(id), State: state})
+	}
+	m.tw.Add(&cmn.Job{UUID: cmn.EncodeItemID(4), Interval: 60})
+	for id := 1; id <= 4; id++ {
+		if err := ioutil.WriteFile(m.scriptFile(int64(id)), []byte("ls"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scripts != 2 {
+		t.Errorf("compacted scripts: %d", stats.Scripts)
+	}
+	for id, kept := range map[int64]bool{1: true, 2: false, 3: false, 4: true} {
+		_, err := os.Stat(m.scriptFile(id))
+		if (err == nil) != kept {
+			t.Errorf("script %d kept: %v, want %v", id, err == nil, kept)
+		}
+	}
+}
+
+func TestCompactDeliveries(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	m.opts.Retention = Retention{EventMaxAge: time.Hour, DeliveryMaxAge: 2 * time.Hour}
+	now := time.Now().Unix()
+
+	deliveries := []Delivery{
+		{Seq: 1, Status: DeliveryDelivered, Updated: now - 3*3600},
+		{Seq: 2, Status: DeliveryFailed, Updated: now - 5400},
+		{Seq: 3, Status: DeliveryPending, Updated: now - 3*3600},
+	}
+	for _, d := range deliveries {
+		data, err := json.Marshal(&d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.dbWebhook.Put(store.SeqKey(d.Seq), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// delivery 2 is older than max age of events, but kept by max age of deliveries.
+	stats, err := m.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Deliveries != 1 {
+		t.Errorf("compacted deliveries: %d", stats.Deliveries)
+	}
+	for seq, kept := range map[uint64]bool{1: false, 2: true, 3: true} {
+		if ok, _ := m.dbWebhook.Has(store.SeqKey(seq)); ok != kept {
+			t.Errorf("delivery %d kept: %v, want %v", seq, ok, kept)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"airman.com/airtask/node/store"
+	fs "airman.com/airtask/node/subscribe"
+)
+
+// Options is setting of manager, it is used when airtask is embedded as library.
+type Options struct {
+	DataDir     string        // data directory of task and result database
+	NodeID      string        // node id of snowflake id generator
+	Interval    time.Duration // tick interval of time wheel
+	SlotNum     int           // slot number of time wheel
+	QueueSize   int           // unused, tasks are not queued, kept for compatibility
+	Engine      string        // storage engine: leveldb, bolt or memory
+	ExecTimeout time.Duration // max duration of a run of task, no limit if it is zero
+	Retention   Retention     // retention of tasks, results and scripts
+	Subscribe   fs.Config     // buffer size and overflow policy of subscribers
+	Webhook     WebhookOptions
+	Health      HealthOptions
+}
+
+// Retention is setting of compactor, zero value of limit means no limit.
+type Retention struct {
+	MaxAge         time.Duration // max age of finished tasks and results
+	MaxRuns        int           // max runs of results kept per task
+	MaxSize        int64         // max total size of results in bytes
+	EventMaxAge    time.Duration // max age of events in event log, default is one day
+	DeliveryMaxAge time.Duration // max age of finished webhook deliveries, default is seven days
+	Interval       time.Duration // interval of compaction
+}
+
+// DefaultOptions returns options with default time wheel settings.
+func DefaultOptions(dataDir string) *Options {
+	return &Options{
+		DataDir:   dataDir,
+		Interval:  DefaultInterval,
+		SlotNum:   DefaultSlotNum,
+		QueueSize: MaxChanSize,
+		Engine:    store.EngineLevelDB,
+		Retention: Retention{
+			EventMaxAge:    DefaultEventMaxAge,
+			DeliveryMaxAge: DefaultDeliveryMaxAge,
+			Interval:       DefaultCompactInterval,
+		},
+		Webhook: WebhookOptions{
+			MaxAttempts: DefaultWebhookAttempts,
+			MinBackoff:  DefaultWebhookMinBackoff,
+			MaxBackoff:  DefaultWebhookMaxBackoff,
+			Timeout:     DefaultWebhookTimeout,
+		},
+		Health: HealthOptions{MaxTickAge: DefaultMaxTickAge, MaxLockWait: DefaultMaxLockWait},
+	}
+}
+
+// localBackend is backend of manager without admin node.
+type localBackend struct {
+	dataDir string
+	nodeID  string
+}
+
+// DataDir returns data directory.
+func (b *localBackend) DataDir() string {
+	return b.dataDir
+}
+
+// NodeID returns node id.
+func (b *localBackend) NodeID() string {
+	return b.nodeID
+}
+
+// New creates a manager for in-process use, no RPC listener is opened.
+// The manager should be started by Start and stopped by Stop.
+func New(opts *Options) (*Manager, error) {
+	if opts == nil || opts.DataDir == "" {
+		return nil, ErrNoDataDir
+	}
+
+	dataDir, err := filepath.Abs(opts.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	o := *opts
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+	if o.SlotNum <= 0 {
+		o.SlotNum = DefaultSlotNum
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = MaxChanSize
+	}
+	if o.Retention.EventMaxAge <= 0 {
+		o.Retention.EventMaxAge = DefaultEventMaxAge
+	}
+	if o.Retention.DeliveryMaxAge <= 0 {
+		o.Retention.DeliveryMaxAge = DefaultDeliveryMaxAge
+	}
+	if o.Retention.Interval <= 0 {
+		o.Retention.Interval = DefaultCompactInterval
+	}
+	if o.Webhook.MaxAttempts <= 0 {
+		o.Webhook.MaxAttempts = DefaultWebhookAttempts
+	}
+	if o.Webhook.MinBackoff <= 0 {
+		o.Webhook.MinBackoff = DefaultWebhookMinBackoff
+	}
+	if o.Webhook.MaxBackoff <= 0 {
+		o.Webhook.MaxBackoff = DefaultWebhookMaxBackoff
+	}
+	if o.Webhook.Timeout <= 0 {
+		o.Webhook.Timeout = DefaultWebhookTimeout
+	}
+
+	backend := &localBackend{dataDir: dataDir, nodeID: opts.NodeID}
+	return NewManagerWithOptions(backend, &o), nil
+}
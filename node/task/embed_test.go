@@ -0,0 +1,83 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"bytes"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// checkNotRunning calls methods which need opened stores.
+func checkNotRunning(t *testing.T, m *Manager, id int64, when string) {
+	calls := map[string]error{}
+	_, calls["AddTask"] = m.AddTask(&cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, Retry: 1, Interval: 5, Extra: []byte("true")})
+	_, calls["GetTask"] = m.GetTask(&cmn.Job{UUID: cmn.EncodeItemID(uint64(id))})
+	_, calls["GetTaskV2"] = m.GetTaskV2(id)
+	_, calls["Job"] = m.Job(id)
+	_, calls["CheckTask"] = m.CheckTask(&cmn.Job{UUID: cmn.EncodeItemID(uint64(id))})
+	_, calls["Result"] = m.Result(id)
+	_, calls["ListTasks"] = m.ListTasks(nil)
+	_, calls["ListResults"] = m.ListResults(id, nil)
+	_, _, calls["ListEvents"] = m.ListEvents(0, 0, "")
+	_, calls["Stats"] = m.Stats()
+	_, calls["AuditLog"] = m.AuditLog(nil)
+	_, calls["Deliveries"] = m.Deliveries(nil)
+	_, calls["Export"] = m.Export(&bytes.Buffer{}, false)
+	_, calls["Compact"] = m.Compact()
+	calls["PauseTask"] = m.PauseTask(id)
+	calls["ResumeTask"] = m.ResumeTask(id)
+	calls["Delete"] = m.Delete(id)
+	for name, err := range calls {
+		if err != ErrManagerNotRunning {
+			t.Errorf("%s %s: %v, want %v", name, when, err, ErrManagerNotRunning)
+		}
+	}
+}
+
+func TestEmbedNotRunning(t *testing.T) {
+	m, err := New(&Options{DataDir: t.TempDir(), NodeID: "1", Engine: store.EngineBolt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNotRunning(t, m, 1, "before start")
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	id, err := m.AddTask(&cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, Retry: 1, Interval: 3600, Extra: []byte("true")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetTask(&cmn.Job{UUID: cmn.EncodeItemID(uint64(id))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	checkNotRunning(t, m, id, "after stop")
+
+	// task is kept and deleted after restart.
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Stop()
+	if err := m.Delete(id); err != nil {
+		t.Fatal(err)
+	}
+}
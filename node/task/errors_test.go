@@ -0,0 +1,67 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"errors"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func TestAPIErrors(t *testing.T) {
+	m := newTestManager()
+	api := NewPrivateTaskAPI(m)
+	name := "dev"
+
+	// missing task and result are not found.
+	_, err := api.GetTaskV2(JobArgs{Name: &name, UUID: 1})
+	if !cmn.IsNotFound(err) {
+		t.Fatalf("get missing task: %v, want not found", err)
+	}
+	if d := err.(*cmn.Error).Data; d.Kind != "task" || d.ID != 1 {
+		t.Fatalf("data: %#v", d)
+	}
+	if _, err := api.GetResultV2(JobArgs{Name: &name, UUID: 1}); !cmn.IsNotFound(err) {
+		t.Fatalf("get missing result: %v, want not found", err)
+	}
+	if _, err := api.CheckTask(JobArgs{Name: &name, UUID: 1}); !cmn.IsNotFound(err) {
+		t.Fatalf("check missing task: %v, want not found", err)
+	}
+	if _, err := api.ListResults(ResultArgs{UUID: 1}); !cmn.IsNotFound(err) {
+		t.Fatalf("list results of missing task: %v, want not found", err)
+	}
+
+	// invalid argument reports its field.
+	_, err = api.GetTaskV2(JobArgs{UUID: 1})
+	if cmn.ErrorCodeOf(err) != cmn.CodeInvalid || err.(*cmn.Error).Data.Field != "name" {
+		t.Fatalf("get without name: %v, want invalid name", err)
+	}
+
+	// finished task can not be paused.
+	job := &cmn.Job{Name: name, Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2), State: cmn.JobStateSucceeded}
+	putTestJob(t, m, job)
+	err = m.taskError("task", 2, m.PauseTask(2))
+	if cmn.ErrorCodeOf(err) != cmn.CodeConflict || err.(*cmn.Error).Data.State != "succeeded" {
+		t.Fatalf("pause finished task: %v, want conflict", err)
+	}
+
+	// storage errors of stopped manager are unavailable.
+	m.ready = 0
+	if err := m.taskError("task", 2, errors.New("leveldb: closed")); err != ErrManagerNotRunning {
+		t.Fatalf("stopped manager: %v, want %v", err, ErrManagerNotRunning)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"time"
+
+	"airman.com/airfk/pkg/event"
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+	fs "airman.com/airtask/node/subscribe"
+)
+
+const (
+	DefaultEventMaxAge = 24 * time.Hour
+	DefaultEventLimit  = 100
+	MaxEventLimit      = 1000
+)
+
+// SubscribeEvents registers a subscription of events appended to event log.
+func (m *Manager) SubscribeEvents(ch chan<- []cmn.Event) event.Subscription {
+	return m.eventScope.Track(m.eventsFeed.Subscribe(ch))
+}
+
+// appendResultEvents appends events of results to event log.
+func (m *Manager) appendResultEvents(results []cmn.Result) {
+	events := make([]cmn.Event, len(results))
+	for i := range results {
+		events[i] = cmn.Event{Type: cmn.EventResult, ID: results[i].ID, Result: &results[i]}
+	}
+	m.appendEvents(events)
+}
+
+// appendEvents numbers events, writes them into event log and sends them to
+// subscribers. Events are sent in order of sequence.
+func (m *Manager) appendEvents(events []cmn.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	m.eventMu.Lock()
+	defer m.eventMu.Unlock()
+
+	now := time.Now().Unix()
+	seq := m.eventSeq
+	b := m.dbEvent.NewBatch()
+	for i := range events {
+		seq++
+		events[i].Seq = seq
+		events[i].Time = now
+
+		eventBytes, err := json.Marshal(&events[i])
+		if err != nil {
+			log.Errorf("json marshal event error, %#v, %v", events[i], err)
+			return
+		}
+		if err := b.Put(m.dbEvent, store.EventKey(seq), eventBytes); err != nil {
+			log.Errorf("db put event error, %d, %v", seq, err)
+			return
+		}
+	}
+	if err := b.Put(m.dbMeta, store.EventSeqKey, store.SeqKey(seq)); err != nil {
+		log.Errorf("db put event sequence error, %d, %v", seq, err)
+		return
+	}
+	if err := b.Write(); err != nil {
+		log.Errorf("write event log error, %d, %v", seq, err)
+		return
+	}
+	m.eventSeq = seq
+	m.eventsFeed.Send(events)
+}
+
+// ListEvents lists events of the given type from sequence from, inclusive. Next
+// is sequence of first event of next page, it is 0 if no more events.
+func (m *Manager) ListEvents(from uint64, limit int, typ string) ([]cmn.Event, uint64, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, 0, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultEventLimit
+	} else if limit > MaxEventLimit {
+		limit = MaxEventLimit
+	}
+
+	var (
+		events = make([]cmn.Event, 0, limit)
+		next   uint64
+	)
+	err := m.dbEvent.Iterate(nil, store.EventKey(from), func(key, value []byte) bool {
+		var ev cmn.Event
+		if err := json.Unmarshal(value, &ev); err != nil {
+			log.Errorf("json unmarshal event error, %x, %v", key, err)
+			return true
+		}
+		if typ != "" && ev.Type != typ {
+			return true
+		}
+		if len(events) == limit {
+			next = ev.Seq
+			return false
+		}
+		events = append(events, ev)
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, next, nil
+}
+
+// eventCursor sends events of a type in order of sequence, events of event log
+// are replayed before live events and every event is sent once.
+type eventCursor struct {
+	m          *Manager
+	typ        string
+	filter     *fs.Filter
+	last       uint64 // sequence of last sent event
+	maxPending int    // max live events buffered while replaying
+	send       func(cmn.Event)
+}
+
+func newEventCursor(m *Manager, typ string, filter *fs.Filter, send func(cmn.Event)) *eventCursor {
+	return &eventCursor{m: m, typ: typ, filter: filter, maxPending: MaxEventLimit, send: send}
+}
+
+// notify sends events after the last sent one.
+func (c *eventCursor) notify(evs []cmn.Event) {
+	for _, ev := range evs {
+		if ev.Seq <= c.last || ev.Type != c.typ {
+			continue
+		}
+		c.last = ev.Seq
+		if ev.Result != nil && !c.filter.Match(ev.Result) {
+			continue
+		}
+		c.send(ev)
+	}
+}
+
+// replay sends events of event log from sequence from, then live events which
+// are received while replaying. Live events are drained so they are not dropped
+// by overflow policy of subscriber buffer. If more than maxPending live events
+// are received, they are dropped and read from event log again, as every live
+// event is written into event log before it is sent.
+func (c *eventCursor) replay(from uint64, live <-chan []cmn.Event) {
+	if from == 0 {
+		return
+	}
+	c.last = from - 1
+
+	var (
+		pending []cmn.Event
+		dropped bool
+	)
+	for next := from; next > 0; {
+		evs, n, err := c.m.ListEvents(next, MaxEventLimit, c.typ)
+		if err != nil {
+			log.Errorf("replay event log error, %d, %v", next, err)
+			break
+		}
+		c.notify(evs)
+		next = n
+
+	drain:
+		for {
+			select {
+			case evs := <-live:
+				if dropped || len(pending)+len(evs) > c.maxPending {
+					pending, dropped = nil, true
+					continue
+				}
+				pending = append(pending, evs...)
+			default:
+				break drain
+			}
+		}
+		if next == 0 && dropped {
+			next, dropped = c.last+1, false
+		}
+	}
+	c.notify(pending)
+}
+
+// compactEvents deletes events older than deadline, events are ordered by time.
+func (m *Manager) compactEvents(deadline int64, stats *CompactStats) error {
+	var keys [][]byte
+	err := m.dbEvent.Iterate(nil, nil, func(key, value []byte) bool {
+		var ev cmn.Event
+		if err := json.Unmarshal(value, &ev); err == nil && ev.Time >= deadline {
+			return false
+		}
+		keys = append(keys, key)
+		stats.Bytes += int64(len(key) + len(value))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := deleteKeys(m.dbEvent, keys); err != nil {
+		return err
+	}
+	stats.Events += len(keys)
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+	fs "airman.com/airtask/node/subscribe"
+)
+
+func appendTestEvents(m *Manager, typ string, n int) {
+	events := make([]cmn.Event, n)
+	for i := range events {
+		events[i] = cmn.Event{Type: typ, ID: int64(i + 1)}
+		if typ == cmn.EventResult {
+			events[i].Result = cmn.NewResultWithEnd(int64(i+1), 1, 2, "success", nil)
+		}
+	}
+	m.appendEvents(events)
+}
+
+func eventSeqs(events []cmn.Event) []uint64 {
+	seqs := make([]uint64, len(events))
+	for i := range events {
+		seqs[i] = events[i].Seq
+	}
+	return seqs
+}
+
+func equalSeqs(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestListEvents(t *testing.T) {
+	m := newTestManager()
+	appendTestEvents(m, cmn.EventNewTask, 3) // 1-3
+	appendTestEvents(m, cmn.EventResult, 2)  // 4-5
+	appendTestEvents(m, cmn.EventNewTask, 2) // 6-7
+
+	tests := []struct {
+		from  uint64
+		limit int
+		typ   string
+		seqs  []uint64
+		next  uint64
+	}{
+		{1, 0, "", []uint64{1, 2, 3, 4, 5, 6, 7}, 0},
+		{1, 3, "", []uint64{1, 2, 3}, 4},
+		{4, 3, "", []uint64{4, 5, 6}, 7},
+		{7, 3, "", []uint64{7}, 0},
+		{2, 2, cmn.EventNewTask, []uint64{2, 3}, 6},
+		{6, 2, cmn.EventNewTask, []uint64{6, 7}, 0},
+		{1, 10, cmn.EventResult, []uint64{4, 5}, 0},
+		{8, 10, "", []uint64{}, 0},
+	}
+	for i, tt := range tests {
+		events, next, err := m.ListEvents(tt.from, tt.limit, tt.typ)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if seqs := eventSeqs(events); !equalSeqs(seqs, tt.seqs) || next != tt.next {
+			t.Errorf("%d: events %v next %d, want %v next %d", i, seqs, next, tt.seqs, tt.next)
+		}
+	}
+}
+
+func TestCompactEvents(t *testing.T) {
+	m := newTestManager()
+	now := time.Now().Unix()
+	for seq := uint64(1); seq <= 5; seq++ {
+		ev := cmn.Event{Seq: seq, Time: now - int64(60*(5-seq)), Type: cmn.EventNewTask, ID: int64(seq)}
+		data, err := json.Marshal(&ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.dbEvent.Put(store.EventKey(seq), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// events 1-3 are 2 minutes or older
+	var stats CompactStats
+	if err := m.compactEvents(now-90, &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Events != 3 || stats.Bytes == 0 {
+		t.Errorf("stats: %+v", stats)
+	}
+	events, _, err := m.ListEvents(1, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seqs := eventSeqs(events); !equalSeqs(seqs, []uint64{4, 5}) {
+		t.Errorf("events after compact: %v", seqs)
+	}
+}
+
+func TestEventReplay(t *testing.T) {
+	m := newTestManager()
+	appendTestEvents(m, cmn.EventNewTask, 4) // 1-4
+	appendTestEvents(m, cmn.EventResult, 1)  // 5
+
+	events, _, err := m.ListEvents(1, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// live events received while replaying overlap with event log
+	live := make(chan []cmn.Event, 2)
+	live <- events[2:4]
+	live <- []cmn.Event{{Seq: 6, Type: cmn.EventNewTask, ID: 6}}
+
+	var sent []cmn.Event
+	c := newEventCursor(m, cmn.EventNewTask, nil, func(ev cmn.Event) { sent = append(sent, ev) })
+	c.replay(2, live)
+
+	if seqs := eventSeqs(sent); !equalSeqs(seqs, []uint64{2, 3, 4, 6}) {
+		t.Errorf("replayed: %v", seqs)
+	}
+	c.notify(events)
+	if len(sent) != 4 {
+		t.Errorf("events are sent again: %v", eventSeqs(sent))
+	}
+}
+
+func TestEventReplayOverflow(t *testing.T) {
+	m := newTestManager()
+	appendTestEvents(m, cmn.EventNewTask, 3) // 1-3
+
+	live := make(chan []cmn.Event, 16)
+	sub := m.SubscribeEvents(live)
+	defer sub.Unsubscribe()
+
+	// events appended while replaying exceed pending limit, they are read
+	// from event log again.
+	var sent []cmn.Event
+	c := newEventCursor(m, cmn.EventNewTask, nil, func(ev cmn.Event) {
+		sent = append(sent, ev)
+		if ev.Seq == 1 {
+			appendTestEvents(m, cmn.EventNewTask, 2) // 4-5
+			appendTestEvents(m, cmn.EventNewTask, 2) // 6-7
+		}
+	})
+	c.maxPending = 3
+	c.replay(1, live)
+
+	if seqs := eventSeqs(sent); !equalSeqs(seqs, []uint64{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("replayed: %v", seqs)
+	}
+}
+
+func TestEventCursorFilter(t *testing.T) {
+	m := newTestManager()
+	appendTestEvents(m, cmn.EventResult, 3)
+
+	filter := &fs.Filter{IDs: []int64{2}}
+	if err := filter.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var sent []cmn.Event
+	c := newEventCursor(m, cmn.EventResult, filter, func(ev cmn.Event) { sent = append(sent, ev) })
+	c.replay(1, nil)
+
+	if len(sent) != 1 || sent[0].ID != 2 {
+		t.Errorf("filtered: %v", eventSeqs(sent))
+	}
+	if c.last != 3 {
+		t.Errorf("last: %d", c.last)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func TestRegisterHandlerWithErr(t *testing.T) {
+	m := newTestManager()
+
+	var (
+		calls []string // name and extra of task got by handler
+		errs  []error  // errors got by error handler
+		fails = map[int64]int{1: 1, 2: 1}
+	)
+	errFail := errors.New("handler failed")
+	err := m.RegisterHandlerWithErr("echo@1.0.0", func(ctx context.Context) error {
+		job, ok := cmn.JobFromContext(ctx)
+		if !ok {
+			return errors.New("no task in context")
+		}
+		calls = append(calls, job.Name+":"+string(job.Extra))
+		if fails[job.ID()] > 0 {
+			fails[job.ID()]--
+			return errFail
+		}
+		return nil
+	}, func(ctx context.Context, err error) {
+		errs = append(errs, err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, retry := range []int{1, 2, 1} {
+		putTestJob(t, m, &cmn.Job{
+			Name:     "dev",
+			Type:     cmn.JobTypePlugin,
+			UUID:     cmn.EncodeItemID(uint64(i + 1)),
+			Retry:    retry,
+			Interval: 10,
+			State:    cmn.JobStateScheduled,
+			Extra:    []byte("echo@1.0.0"),
+		})
+	}
+	m.executeHandle([]int64{1, 2})
+
+	if len(calls) != 3 {
+		t.Fatalf("handler calls: %v, want 3", calls)
+	}
+	for _, call := range calls {
+		if call != "dev:echo@1.0.0" {
+			t.Fatalf("handler got task %s", call)
+		}
+	}
+	if len(errs) != 2 || errs[0] != errFail || errs[1] != errFail {
+		t.Fatalf("error handler got %v", errs)
+	}
+
+	want := map[int64]struct {
+		state cmn.JobState
+		err   error
+	}{
+		1: {cmn.JobStateFailed, errFail},
+		2: {cmn.JobStateSucceeded, nil},
+	}
+	for id, w := range want {
+		r, err := m.Result(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.ErrorMsg != cmn.ToMsg(w.err) || len(r.Extra) != 0 || r.Type != cmn.JobTypePlugin {
+			t.Errorf("result of task %d: %#v", id, r)
+		}
+		if job, err := m.getJob(uint64(id)); err != nil || job.State != w.state {
+			t.Errorf("task %d: %#v, %v, want %s", id, job, err, w.state)
+		}
+	}
+
+	// scheduled task of unregistered handler fails without calling it.
+	if !m.UnregisterHandler("echo@1.0.0") {
+		t.Fatal("handler is not unregistered")
+	}
+	m.executeHandle([]int64{3})
+	if len(calls) != 3 {
+		t.Fatalf("unregistered handler is called: %v", calls)
+	}
+	r, err := m.Result(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ErrorMsg != cmn.ToMsg(cmn.ErrInvalidPluginName) {
+		t.Fatalf("result of unregistered handler: %#v", r)
+	}
+	if job, err := m.getJob(3); err != nil || job.State != cmn.JobStateFailed {
+		t.Fatalf("task of unregistered handler: %#v, %v", job, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultMaxTickAge  = 30 * time.Second
+	DefaultMaxLockWait = 5 * time.Minute
+)
+
+// status of health check
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// HealthOptions is setting of liveness and readiness checks.
+type HealthOptions struct {
+	MaxTickAge  time.Duration // max time since last tick of time wheel when ready
+	MaxLockWait time.Duration // max time update loop waits for manager lock when live
+}
+
+// Check is result of a health check.
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Health is status and checks of liveness or readiness.
+type Health struct {
+	Status string  `json:"status"`
+	Checks []Check `json:"checks"`
+}
+
+// OK returns all checks are passed or not.
+func (h *Health) OK() bool {
+	return h.Status == HealthOK
+}
+
+func newHealth(checks []Check) *Health {
+	h := &Health{Status: HealthOK, Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			h.Status = HealthFail
+		}
+	}
+	return h
+}
+
+func check(name string, err error) Check {
+	if err != nil {
+		return Check{Name: name, Error: err.Error()}
+	}
+	return Check{Name: name, OK: true}
+}
+
+// lockUpdate acquires manager lock in update loop and records time of waiting,
+// so liveness finds the loop stuck behind the lock.
+func (m *Manager) lockUpdate() {
+	atomic.StoreInt64(&m.lockWait, time.Now().UnixNano())
+	m.mu.Lock()
+	atomic.StoreInt64(&m.lockWait, 0)
+}
+
+// Live checks update loop is not stuck behind manager lock. It never takes
+// manager lock. Executions release the lock while tasks run, so a long task
+// does not fail it.
+func (m *Manager) Live() *Health {
+	maxWait := m.opts.Health.MaxLockWait
+	if maxWait <= 0 {
+		maxWait = DefaultMaxLockWait
+	}
+
+	var err error
+	if since := atomic.LoadInt64(&m.lockWait); since > 0 {
+		if wait := time.Since(time.Unix(0, since)); wait > maxWait {
+			err = fmt.Errorf("update loop waits for lock for %s", wait.Round(time.Second))
+		}
+	}
+	return newHealth([]Check{check("update", err)})
+}
+
+// Ready checks manager is started, task and result databases are open, module
+// watcher is running and time wheel ticked recently. It never takes manager lock.
+func (m *Manager) Ready() *Health {
+	if atomic.LoadInt32(&m.ready) == 0 {
+		return newHealth([]Check{check("started", ErrManagerNotRunning)})
+	}
+
+	maxAge := m.opts.Health.MaxTickAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxTickAge
+	}
+
+	var tickErr error
+	if age := time.Since(time.Unix(0, atomic.LoadInt64(&m.lastTick))); age > maxAge {
+		tickErr = fmt.Errorf("last tick is %s ago", age.Round(time.Second))
+	}
+	var watchErr error
+	if !m.watchModule.IsRunning() {
+		watchErr = fmt.Errorf("module watcher is stopped")
+	}
+
+	return newHealth([]Check{
+		check("started", nil),
+		check("task_db", probe(m.dbMeta)),
+		check("result_db", probe(m.dbResult)),
+		check("watcher", watchErr),
+		check("tick", tickErr),
+	})
+}
+
+// probe reads a key to check database is open.
+func probe(db *store.Store) error {
+	_, err := db.Has(store.SchemaVersionKey)
+	return err
+}
+
+// HealthHandler returns handler which writes health in JSON, status code is 503
+// if any check fails.
+func HealthHandler(health func() *Health) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := health()
+		w.Header().Set("Content-Type", "application/json")
+		if !h.OK() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func TestReady(t *testing.T) {
+	m := newTestManager()
+	m.ready = 0
+	if h := m.Ready(); h.OK() {
+		t.Fatalf("manager not started is ready: %#v", h)
+	}
+
+	m.ready = 1
+	m.lastTick = time.Now().UnixNano()
+	m.watchModule = &Watcher{running: 1}
+	if h := m.Ready(); !h.OK() {
+		t.Fatalf("started manager is not ready: %#v", h)
+	}
+
+	m.lastTick = time.Now().Add(-time.Minute).UnixNano()
+	h := m.Ready()
+	if h.OK() {
+		t.Fatal("manager with stale tick is ready")
+	}
+	for _, c := range h.Checks {
+		if c.OK != (c.Name != "tick") {
+			t.Fatalf("check %s: %v", c.Name, c.OK)
+		}
+	}
+}
+
+func TestLive(t *testing.T) {
+	m := newTestManager()
+	handler := HealthHandler(m.Live)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// update loop waits for lock longer than max lock wait.
+	m.lockWait = time.Now().Add(-2 * DefaultMaxLockWait).UnixNano()
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status: %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLiveDuringRun(t *testing.T) {
+	m := newTestManager()
+	m.opts.Health.MaxLockWait = 100 * time.Millisecond
+
+	started, release := make(chan struct{}), make(chan struct{})
+	err := m.RegisterHandler("block", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := &cmn.Job{Name: "dev", Type: cmn.JobTypePlugin, UUID: cmn.EncodeItemID(1),
+		Extra: []byte("block"), Retry: 1, AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled}
+	putTestJob(t, m, job)
+	m.inflight[1] = struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		m.executeHandle([]int64{1})
+		close(done)
+	}()
+	<-started
+
+	// update loop takes lock while task is running.
+	locked := make(chan struct{})
+	go func() {
+		m.lockUpdate()
+		m.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("update loop waits for lock of running task")
+	}
+	time.Sleep(2 * m.opts.Health.MaxLockWait)
+	if h := m.Live(); !h.OK() {
+		t.Fatalf("not live while task is running: %#v", h)
+	}
+
+	// running task can not be paused while lock is released.
+	if err := m.PauseTask(1); err == nil {
+		t.Error("running task is paused")
+	}
+
+	close(release)
+	<-done
+	if job, err := m.getJob(1); err != nil || job.State != cmn.JobStateSucceeded {
+		t.Fatalf("job after run: %#v, %v", job, err)
+	}
+	if m.isInflight(1) {
+		t.Error("task is inflight after run")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultResultLimit = 100
+	MaxResultLimit     = 1000
+)
+
+// ResultQuery is condition of listing results of a task.
+type ResultQuery struct {
+	Start uint64 // first run of page, inclusive
+	Limit int    // max number of results
+	From  int64  // lower bound of begin time in unix seconds, inclusive
+	To    int64  // upper bound of begin time in unix seconds, inclusive, 0 is no bound
+}
+
+// ResultPage is a page of results of a task.
+type ResultPage struct {
+	Results []cmn.Result `json:"results"`
+	Next    uint64       `json:"next"` // first run of next page, 0 if no more results
+}
+
+// saveResult stores result as a new run of task and as last result of task in
+// a batch.
+func (m *Manager) saveResult(r *cmn.Result) error {
+	key := cmn.EncodeItemID(uint64(r.ID)).Bytes()
+
+	r.Run = 1
+	if lastBytes, err := m.dbResult.Get(key); err == nil {
+		var last cmn.Result
+		if err := json.Unmarshal(lastBytes, &last); err == nil {
+			r.Run = last.Run + 1
+		}
+	}
+
+	jsonBytes, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b := m.dbResult.NewBatch()
+	if err := b.Put(m.dbHistory, store.RunKey(uint64(r.ID), r.Run), jsonBytes); err != nil {
+		return err
+	}
+	if err := b.Put(m.dbResult, key, jsonBytes); err != nil {
+		return err
+	}
+	return b.Write()
+}
+
+// deleteResults deletes last result and every run of task in a batch. Result
+// left by a crash before it is deleted with task is removed by compactor.
+func (m *Manager) deleteResults(id uint64) error {
+	b := m.dbResult.NewBatch()
+	if err := b.Delete(m.dbResult, cmn.EncodeItemID(id).Bytes()); err != nil {
+		return err
+	}
+	var keys [][]byte
+	err := m.dbHistory.Iterate(cmn.EncodeItemID(id).Bytes(), nil, func(key, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := b.Delete(m.dbHistory, key); err != nil {
+			return err
+		}
+	}
+	return b.Write()
+}
+
+// ListResults lists results of every run of task, ordered by run. ErrNotFound
+// is returned if task does not exist.
+func (m *Manager) ListResults(id int64, q *ResultQuery) (*ResultPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	if ok, err := m.dbTask.Has(cmn.EncodeItemID(uint64(id)).Bytes()); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	if q == nil {
+		q = &ResultQuery{}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultResultLimit
+	} else if limit > MaxResultLimit {
+		limit = MaxResultLimit
+	}
+
+	prefix := cmn.EncodeItemID(uint64(id)).Bytes()
+	var start []byte
+	if q.Start > 0 {
+		start = store.RunKey(uint64(id), q.Start)
+	}
+
+	page := &ResultPage{Results: make([]cmn.Result, 0, limit)}
+	err := m.dbHistory.Iterate(prefix, start, func(key, value []byte) bool {
+		var r cmn.Result
+		if err := json.Unmarshal(value, &r); err != nil {
+			log.Errorf("json unmarshal result error, %x, %v", key, err)
+			return true
+		}
+		if q.To > 0 && r.BeginTime > q.To {
+			return false
+		}
+		if r.BeginTime < q.From {
+			return true
+		}
+		if len(page.Results) == limit {
+			page.Next = binary.BigEndian.Uint64(key[8:])
+			return false
+		}
+		page.Results = append(page.Results, r)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
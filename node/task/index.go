@@ -0,0 +1,190 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// putJob writes job with its indexes into batch, index entries of the stored
+// job are deleted if its name, state or fire time is changed.
+func (m *Manager) putJob(b *store.Batch, job *cmn.Job) error {
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	id := job.UUID.Uint64()
+	old, err := m.getJob(id)
+	if err == store.ErrNotFound {
+		old = nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := b.Put(m.dbTask, job.UUID.Bytes(), jobBytes); err != nil {
+		return err
+	}
+	if old != nil && old.Name != job.Name {
+		if err := b.Delete(m.dbIndex, store.NameIndexKey(old.Name, id)); err != nil {
+			return err
+		}
+	}
+	if err := b.Put(m.dbIndex, store.NameIndexKey(job.Name, id), nil); err != nil {
+		return err
+	}
+	return m.putState(b, old, job)
+}
+
+// putState moves job from state and fire time of old job, which is nil for new
+// job, to its own in state index. Job waiting for its fire time is in fire index.
+func (m *Manager) putState(b *store.Batch, old, job *cmn.Job) error {
+	id := job.UUID.Uint64()
+	if old != nil {
+		if old.State != job.State {
+			if err := b.Delete(m.dbIndex, store.StateIndexKey(old.State.String(), id)); err != nil {
+				return err
+			}
+		}
+		if waitsFire(old.State) && (!waitsFire(job.State) || nextFire(old) != nextFire(job)) {
+			if err := b.Delete(m.dbIndex, store.FireIndexKey(nextFire(old), id)); err != nil {
+				return err
+			}
+		}
+	}
+	if waitsFire(job.State) {
+		if err := b.Put(m.dbIndex, store.FireIndexKey(nextFire(job), id), nil); err != nil {
+			return err
+		}
+	}
+	return b.Put(m.dbIndex, store.StateIndexKey(job.State.String(), id), nil)
+}
+
+// indexKeys returns index keys of job.
+func indexKeys(job *cmn.Job) [][]byte {
+	id := job.UUID.Uint64()
+	keys := [][]byte{
+		store.NameIndexKey(job.Name, id),
+		store.StateIndexKey(job.State.String(), id),
+	}
+	if waitsFire(job.State) {
+		keys = append(keys, store.FireIndexKey(nextFire(job), id))
+	}
+	return keys
+}
+
+// deleteJob deletes job with its indexes in batch.
+func (m *Manager) deleteJob(b *store.Batch, job *cmn.Job) error {
+	id := job.UUID.Uint64()
+	if err := b.Delete(m.dbTask, job.UUID.Bytes()); err != nil {
+		return err
+	}
+	if err := b.Delete(m.dbIndex, store.NameIndexKey(job.Name, id)); err != nil {
+		return err
+	}
+	if err := b.Delete(m.dbIndex, store.FireIndexKey(nextFire(job), id)); err != nil {
+		return err
+	}
+	for _, s := range cmn.JobStates {
+		if err := b.Delete(m.dbIndex, store.StateIndexKey(s.String(), id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transit moves job to the given state and writes job with state index.
+func (m *Manager) transit(job *cmn.Job, to cmn.JobState) error {
+	if err := job.Transit(to, time.Now().Unix()); err != nil {
+		return err
+	}
+	b := m.dbTask.NewBatch()
+	if err := m.putJob(b, job); err != nil {
+		return err
+	}
+	return b.Write()
+}
+
+// getJob returns job by id.
+func (m *Manager) getJob(id uint64) (*cmn.Job, error) {
+	key := cmn.EncodeItemID(id)
+	jobBytes, err := m.dbTask.Get(key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	job := new(cmn.Job)
+	if err := json.Unmarshal(jobBytes, job); err != nil {
+		return nil, err
+	}
+	job.UUID = key
+	return job, nil
+}
+
+// jobsInState returns ids of jobs in the given state by state index.
+func (m *Manager) jobsInState(state cmn.JobState) ([]uint64, error) {
+	var ids []uint64
+	err := m.dbIndex.Iterate(store.StateIndexPrefix(state.String()), nil, func(key, value []byte) bool {
+		ids = append(ids, store.IndexNumber(key))
+		return true
+	})
+	return ids, err
+}
+
+// recoverTasks adds scheduled tasks in state index into time wheel again.
+func (m *Manager) recoverTasks() error {
+	ids, err := m.jobsInState(cmn.JobStateScheduled)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		job, err := m.getJob(id)
+		if err != nil {
+			log.Errorf("recover task error, %d: %v", id, err)
+			continue
+		}
+		m.schedule(job)
+	}
+	log.Infof("recover tasks: %d", len(ids))
+	return nil
+}
+
+// schedule adds job into time wheel by its next fire time, job which should
+// have been fired is fired at next tick.
+func (m *Manager) schedule(job *cmn.Job) {
+	delay := nextFire(job) - time.Now().Unix()
+	if delay < 1 {
+		delay = 1
+	}
+	m.tw.Add(&cmn.Job{UUID: job.UUID, Interval: int(delay)})
+}
+
+// waitsFire returns job in the state waits for its fire time.
+func waitsFire(state cmn.JobState) bool {
+	return state == cmn.JobStateScheduled || state == cmn.JobStatePaused
+}
+
+// nextFire returns fire time of job in unix seconds.
+func nextFire(job *cmn.Job) int64 {
+	return job.AddTime + int64(job.Interval)
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"time"
+
+	"airman.com/airfk/pkg/event"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// SubscribeLifecycleEvent registers a subscription of lifecycle events of
+// tasks and modules.
+func (m *Manager) SubscribeLifecycleEvent(ch chan<- cmn.LifecycleEvent) event.Subscription {
+	return m.lifecycleScope.Track(m.lifecycleFeed.Subscribe(ch))
+}
+
+// publishTask sends lifecycle event of task.
+func (m *Manager) publishTask(typ cmn.LifecycleType, job *cmn.Job, attempt int, err error) {
+	ev := cmn.LifecycleEvent{
+		Type:    typ,
+		Time:    time.Now().Unix(),
+		ID:      job.UUID.Int64(),
+		Name:    job.Name,
+		Attempt: attempt,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	m.lifecycleFeed.Send(ev)
+}
+
+// publishModule sends lifecycle event of module.
+func (m *Manager) publishModule(typ cmn.LifecycleType, id string) {
+	m.lifecycleFeed.Send(cmn.LifecycleEvent{Type: typ, Time: time.Now().Unix(), Module: id})
+}
+
+// isTimeout returns whether error of run is a timeout.
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	if e, ok := err.(interface{ Timeout() bool }); ok {
+		return e.Timeout()
+	}
+	return false
+}
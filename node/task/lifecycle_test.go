@@ -0,0 +1,119 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// lifecycleEvents returns events in channel as type:id:attempt or type:module.
+func lifecycleEvents(ch chan cmn.LifecycleEvent) []string {
+	var evs []string
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Module != "" {
+				evs = append(evs, fmt.Sprintf("%s:%s", ev.Type, ev.Module))
+			} else {
+				evs = append(evs, fmt.Sprintf("%s:%d:%d", ev.Type, ev.ID, ev.Attempt))
+			}
+		default:
+			return evs
+		}
+	}
+}
+
+func checkLifecycle(t *testing.T, ch chan cmn.LifecycleEvent, want ...string) {
+	t.Helper()
+	if evs := lifecycleEvents(ch); fmt.Sprint(evs) != fmt.Sprint(want) {
+		t.Fatalf("events %v, want %v", evs, want)
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+	m.opts.ExecTimeout = 50 * time.Millisecond
+
+	ch := make(chan cmn.LifecycleEvent, 64)
+	sub := m.SubscribeLifecycleEvent(ch)
+	defer sub.Unsubscribe()
+
+	var calls int
+	if err := m.RegisterHandler("flaky", func(ctx context.Context) error {
+		if calls++; calls == 1 {
+			return errors.New("first attempt fails")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegisterHandler("slow@1.0.0", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checkLifecycle(t, ch, "moduleAdded:flaky@"+DefaultVersion, "moduleAdded:slow@1.0.0")
+
+	jobs := []*cmn.Job{
+		{Extra: []byte("flaky"), Retry: 2},
+		{Extra: []byte("slow@1.0.0"), Retry: 1},
+		{Extra: []byte("flaky"), Retry: 1, LimitTime: time.Now().Unix() - 10},
+		{Extra: []byte("flaky"), Retry: 1},
+	}
+	for i, job := range jobs {
+		job.Name = "dev"
+		job.Type = cmn.JobTypePlugin
+		job.UUID = cmn.EncodeItemID(uint64(i + 1))
+		job.Interval = 10
+		job.State = cmn.JobStateScheduled
+		putTestJob(t, m, job)
+	}
+
+	m.executeHandle([]int64{1, 2, 3})
+	checkLifecycle(t, ch,
+		"started:1:1", "retrying:1:1", "started:1:2",
+		"started:2:1", "timedOut:2:1",
+		"expired:3:0")
+	for id, state := range map[uint64]cmn.JobState{1: cmn.JobStateSucceeded, 2: cmn.JobStateFailed, 3: cmn.JobStateExpired} {
+		if job, err := m.getJob(id); err != nil || job.State != state {
+			t.Errorf("task %d: %#v, %v, want %s", id, job, err, state)
+		}
+	}
+	if r, err := m.Result(2); err != nil || r.ErrorMsg != cmn.ToMsg(context.DeadlineExceeded) {
+		t.Errorf("result of timed out task: %#v, %v", r, err)
+	}
+
+	// live task is cancelled, then its record is deleted.
+	for i := 0; i < 2; i++ {
+		if err := m.Delete(4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkLifecycle(t, ch, "cancelled:4:0", "deleted:4:0")
+
+	if !m.UnregisterHandler("slow@1.0.0") {
+		t.Fatal("handler is not unregistered")
+	}
+	checkLifecycle(t, ch, "moduleRemoved:slow@1.0.0")
+}
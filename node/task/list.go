@@ -0,0 +1,171 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"airman.com/airfk/pkg/common/hexutil"
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultTaskLimit = 100
+	MaxTaskLimit     = 1000
+)
+
+// TaskQuery is condition of listing tasks, zero value of field means no filter.
+type TaskQuery struct {
+	Name     string       // name of task
+	Type     cmn.JobType  // type of task
+	State    cmn.JobState // state of task
+	FireFrom int64        // lower bound of next fire time in unix seconds, inclusive
+	FireTo   int64        // upper bound of next fire time in unix seconds, inclusive
+	Cursor   []byte       // cursor of first task of page, returned by last page
+	Limit    int          // max number of tasks
+}
+
+// TaskInfo is task with computed fields.
+type TaskInfo struct {
+	Job      *cmn.Job     `json:"job"`
+	State    cmn.JobState `json:"state"`
+	NextFire int64        `json:"next_fire"`
+}
+
+// TaskPage is a page of tasks.
+type TaskPage struct {
+	Tasks []TaskInfo    `json:"tasks"`
+	Next  hexutil.Bytes `json:"next,omitempty"` // cursor of next page, empty if no more tasks
+}
+
+// match returns task is matched by query or not.
+func (q *TaskQuery) match(info *TaskInfo) bool {
+	if q.Name != "" && info.Job.Name != q.Name {
+		return false
+	}
+	if q.Type != cmn.JobTypeUnkown && info.Job.Type != q.Type {
+		return false
+	}
+	if q.State != cmn.JobStateUnknown && info.State != q.State {
+		return false
+	}
+	if (q.FireFrom > 0 || q.FireTo > 0) && !waitsFire(info.State) {
+		return false
+	}
+	if q.FireFrom > 0 && info.NextFire < q.FireFrom {
+		return false
+	}
+	if q.FireTo > 0 && info.NextFire > q.FireTo {
+		return false
+	}
+	return true
+}
+
+// taskInfo returns task with computed fields.
+func (m *Manager) taskInfo(job *cmn.Job) *TaskInfo {
+	return &TaskInfo{
+		Job:      job,
+		State:    job.State,
+		NextFire: nextFire(job),
+	}
+}
+
+// ListTasks lists tasks by query. Tasks are read from name, state or next fire time
+// index if the filter is set, otherwise from task store ordered by uuid.
+func (m *Manager) ListTasks(q *TaskQuery) (*TaskPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	if q == nil {
+		q = &TaskQuery{}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultTaskLimit
+	} else if limit > MaxTaskLimit {
+		limit = MaxTaskLimit
+	}
+
+	page := &TaskPage{Tasks: make([]TaskInfo, 0, limit)}
+	visit := func(key []byte, job *cmn.Job) bool {
+		info := m.taskInfo(job)
+		if !q.match(info) {
+			return true
+		}
+		if len(page.Tasks) == limit {
+			page.Next = key
+			return false
+		}
+		page.Tasks = append(page.Tasks, *info)
+		return true
+	}
+
+	var (
+		prefix []byte
+		start  = q.Cursor
+		isFire bool
+	)
+	switch {
+	case q.Name != "":
+		prefix = store.NameIndexPrefix(q.Name)
+	case q.State != cmn.JobStateUnknown:
+		prefix = store.StateIndexPrefix(q.State.String())
+	case q.FireFrom > 0 || q.FireTo > 0:
+		prefix = store.FireIndexPrefix()
+		isFire = true
+		if len(start) == 0 {
+			start = store.FireIndexKey(q.FireFrom, 0)
+		}
+	default:
+		err := m.dbTask.Iterate(nil, start, func(key, value []byte) bool {
+			job := new(cmn.Job)
+			if err := json.Unmarshal(value, job); err != nil {
+				log.Errorf("json unmarshal job error, %x, %v", key, err)
+				return true
+			}
+			job.UUID = cmn.EncodeItemID(binary.BigEndian.Uint64(key))
+			return visit(key, job)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return page, nil
+	}
+
+	err := m.dbIndex.Iterate(prefix, start, func(key, value []byte) bool {
+		if isFire && q.FireTo > 0 && store.FireIndexTime(key) > q.FireTo {
+			return false
+		}
+		job, err := m.getJob(store.IndexNumber(key))
+		if err != nil {
+			log.Errorf("get job of index error, %x, %v", key, err)
+			return true
+		}
+		return visit(key, job)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+func taskIDs(page *TaskPage) []uint64 {
+	ids := make([]uint64, len(page.Tasks))
+	for i := range page.Tasks {
+		ids[i] = page.Tasks[i].Job.UUID.Uint64()
+	}
+	return ids
+}
+
+func TestListTasks(t *testing.T) {
+	m := newTestManager()
+
+	// fire time of task n is 1000+10n
+	jobs := []*cmn.Job{
+		{Name: "a", Type: cmn.JobTypeCmd, State: cmn.JobStateScheduled},
+		{Name: "b", Type: cmn.JobTypeCmd, State: cmn.JobStatePaused},
+		{Name: "a", Type: cmn.JobTypeCmd, State: cmn.JobStateSucceeded},
+		{Name: "b", Type: cmn.JobTypeCmd, State: cmn.JobStateScheduled},
+		{Name: "a", Type: cmn.JobTypeCmd, State: cmn.JobStateFailed},
+	}
+	for i, job := range jobs {
+		job.UUID = cmn.EncodeItemID(uint64(i + 1))
+		job.AddTime = 1000
+		job.Interval = 10 * (i + 1)
+		putTestJob(t, m, job)
+	}
+
+	tests := []struct {
+		q   TaskQuery
+		ids []uint64
+	}{
+		{TaskQuery{}, []uint64{1, 2, 3, 4, 5}},
+		{TaskQuery{Name: "a"}, []uint64{1, 3, 5}},
+		{TaskQuery{Name: "a", State: cmn.JobStateFailed}, []uint64{5}},
+		{TaskQuery{State: cmn.JobStateScheduled}, []uint64{1, 4}},
+		{TaskQuery{FireFrom: 1015}, []uint64{2, 4}},
+		{TaskQuery{FireFrom: 1010, FireTo: 1030}, []uint64{1, 2}},
+		{TaskQuery{Name: "a", FireTo: 1050}, []uint64{1}},
+		{TaskQuery{Type: cmn.JobTypeUnkown, Name: "c"}, []uint64{}},
+	}
+	for i, tt := range tests {
+		page, err := m.ListTasks(&tt.q)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if ids := taskIDs(page); !equalSeqs(ids, tt.ids) || len(page.Next) != 0 {
+			t.Errorf("%d: tasks %v next %x, want %v", i, ids, page.Next, tt.ids)
+		}
+	}
+
+	// pages of all tasks and of fire index
+	for _, q := range []TaskQuery{{Limit: 2}, {FireFrom: 1, Limit: 1}} {
+		var ids []uint64
+		for {
+			page, err := m.ListTasks(&q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, taskIDs(page)...)
+			if len(page.Next) == 0 {
+				break
+			}
+			q.Cursor = page.Next
+		}
+		want := []uint64{1, 2, 3, 4, 5}
+		if q.FireFrom > 0 {
+			want = []uint64{1, 2, 4}
+		}
+		if !equalSeqs(ids, want) {
+			t.Errorf("pages of %+v: %v, want %v", q, ids, want)
+		}
+	}
+}
+
+func TestIndexTransit(t *testing.T) {
+	m := newTestManager()
+	job := &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1),
+		AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled}
+	putTestJob(t, m, job)
+
+	fireKey := store.FireIndexKey(1010, 1)
+	steps := []struct {
+		state cmn.JobState
+		fire  bool
+	}{
+		{cmn.JobStatePaused, true},
+		{cmn.JobStateScheduled, true},
+		{cmn.JobStateRunning, false},
+		{cmn.JobStateRetrying, false},
+		{cmn.JobStateRunning, false},
+		{cmn.JobStateSucceeded, false},
+	}
+	for _, step := range steps {
+		if err := m.transit(job, step.state); err != nil {
+			t.Fatalf("transit to %s: %v", step.state, err)
+		}
+		if ok, _ := m.dbIndex.Has(fireKey); ok != step.fire {
+			t.Errorf("%s: fire index %v, want %v", step.state, ok, step.fire)
+		}
+		for _, s := range cmn.JobStates {
+			ok, _ := m.dbIndex.Has(store.StateIndexKey(s.String(), 1))
+			if ok != (s == step.state) {
+				t.Errorf("%s: state index of %s is %v", step.state, s, ok)
+			}
+		}
+		if ok, _ := m.dbIndex.Has(store.NameIndexKey("dev", 1)); !ok {
+			t.Errorf("%s: no name index", step.state)
+		}
+	}
+
+	b := m.dbTask.NewBatch()
+	if err := m.deleteJob(b, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write(); err != nil {
+		t.Fatal(err)
+	}
+	err := m.dbIndex.Iterate(nil, nil, func(key, value []byte) bool {
+		t.Errorf("index of deleted job: %x", key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexes returns all index keys.
+func (m *Manager) indexes(t *testing.T) []string {
+	var keys []string
+	err := m.dbIndex.Iterate(nil, nil, func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// wantIndexes returns sorted index keys of jobs.
+func wantIndexes(jobs ...*cmn.Job) []string {
+	var keys []string
+	for _, job := range jobs {
+		for _, key := range indexKeys(job) {
+			keys = append(keys, string(key))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestIndexUpdate(t *testing.T) {
+	m := newTestManager()
+	job := &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1),
+		AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled}
+	putTestJob(t, m, job)
+
+	// name, fire time and state are changed by overwrite.
+	updated := *job
+	updated.Name, updated.Interval, updated.State = "prod", 20, cmn.JobStatePaused
+	putTestJob(t, m, &updated)
+	if keys, want := m.indexes(t), wantIndexes(&updated); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("indexes %q, want %q", keys, want)
+	}
+	for _, key := range [][]byte{
+		store.NameIndexKey("dev", 1),
+		store.FireIndexKey(1010, 1),
+		store.StateIndexKey(cmn.JobStateScheduled.String(), 1),
+	} {
+		if ok, _ := m.dbIndex.Has(key); ok {
+			t.Errorf("stale index %x is kept", key)
+		}
+	}
+}
@@ -18,19 +18,18 @@ package task
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"errors"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"airman.com/airfk/pkg/common"
 	"airman.com/airfk/pkg/common/cmd"
 	"airman.com/airfk/pkg/event"
-	"airman.com/airfk/pkg/leveldb"
 	"airman.com/airfk/pkg/types"
 	log "github.com/sirupsen/logrus"
 	snowflake "github.com/zheng-ji/goSnowFlake"
@@ -55,9 +54,17 @@ const (
 	DefaultCmdDir    = "shells"
 )
 
+var (
+	ErrNoDataDir         = errors.New("no data directory")
+	ErrManagerRunning    = cmn.NewConflictError("task manager already running", nil)
+	ErrManagerNotRunning = cmn.NewUnavailableError("task manager not running")
+	ErrModuleNotFound    = &cmn.Error{Code: cmn.CodeNotFound, Message: "module not found", Data: &cmn.ErrorDetail{Kind: "module"}}
+)
+
 // Manager workers.
 type Manager struct {
 	backend     Backend
+	opts        Options
 	root        string
 	moduleRoot  string
 	cmdRoot     string
@@ -67,12 +74,17 @@ type Manager struct {
 	watchModule *Watcher
 	dbTask      *store.Store
 	dbResult    *store.Store
+	dbHistory   *store.Store
+	dbIndex     *store.Store
+	dbMeta      *store.Store
+	dbAudit     *store.Store
+	dbEvent     *store.Store
+	dbWebhook   *store.Store
+	dbQueue     *store.Store
 	modules     map[string]*module.Module
+	inflight    map[int64]struct{}           // tasks triggered and not finished
+	cancels     map[int64]context.CancelFunc // cancel runs of tasks being executed
 	isRunning   bool
-	queueSize   int
-	addTask     chan cmn.Job
-	deleteTask  chan int64
-	execTask    chan int64
 
 	resultsFeed event.Feed // feed notifying of task result
 	scope       event.SubscriptionScope
@@ -80,29 +92,72 @@ type Manager struct {
 	addFeed  event.Feed // feed notifying of new task
 	addScope event.SubscriptionScope
 
-	ctx    context.Context
+	auditSeq     uint64        // last sequence of audit log
+	auditOpen    bool          // audit log is opened by Start
+	auditPending []*AuditEntry // entries of calls while audit log is closed
+	auditMu      sync.Mutex
+
+	eventSeq   uint64     // last sequence of event log
+	eventMu    sync.Mutex // lock of appending event log
+	eventsFeed event.Feed // feed notifying of events in event log
+	eventScope event.SubscriptionScope
+
+	hookSeq  uint64        // last sequence of webhook deliveries
+	hookMu   sync.Mutex    // lock of queueing webhook deliveries
+	hookWake chan struct{} // wakes webhook loop when deliveries are queued
+
+	compactMu sync.Mutex // lock of compaction
+
+	statsMu sync.Mutex             // lock of statistics
+	wheel   WheelStats             // occupancy of time wheel at last tick
+	due     int                    // tasks in current slot at last tick
+	nModule int                    // number of modules at last tick
+	running map[int64]*RunningTask // tasks being executed
+	names   map[string]*NameStats  // outcome of runs by task name
+
+	ready    int32 // manager is started, read atomically by readiness
+	lastTick int64 // unix nano of last tick of time wheel
+	lockWait int64 // unix nano since update loop waits for lock, 0 if not waiting
+
+	lifecycleFeed  event.Feed // feed notifying of lifecycle of tasks and modules
+	lifecycleScope event.SubscriptionScope
+
+	ctx    context.Context // context of running manager, it is renewed by Start
 	cancel context.CancelFunc
+	wg     sync.WaitGroup // background workers and executions
+	runMu  sync.Mutex     // lock of Start and Stop
 	mu     sync.RWMutex
 }
 
 func NewManager(backend Backend) *Manager {
-	return NewManagerWithTimeWheel(backend, DefaultInterval, DefaultSlotNum, MaxChanSize)
+	return NewManagerWithOptions(backend, DefaultOptions(backend.DataDir()))
 }
 
 func NewManagerWithTimeWheel(backend Backend, interval time.Duration, slotNum, size int) *Manager {
-	twManager := tw.NewTimeWheel(DefaultInterval, DefaultSlotNum)
+	opts := DefaultOptions(backend.DataDir())
+	opts.Interval = interval
+	opts.SlotNum = slotNum
+	opts.QueueSize = size
+	return NewManagerWithOptions(backend, opts)
+}
+
+// NewManagerWithOptions creates manager with options.
+func NewManagerWithOptions(backend Backend, opts *Options) *Manager {
+	twManager := tw.NewTimeWheel(opts.Interval, opts.SlotNum)
 	ctx, cancel := context.WithCancel(context.Background())
 	Manager := &Manager{
-		backend:    backend,
-		root:       backend.DataDir(),
-		tw:         twManager,
-		modules:    make(map[string]*module.Module),
-		addTask:    make(chan cmn.Job, size),
-		deleteTask: make(chan int64, size),
-		execTask:   make(chan int64, size),
-		queueSize:  size,
-		ctx:        ctx,
-		cancel:     cancel,
+		backend:  backend,
+		root:     backend.DataDir(),
+		opts:     *opts,
+		tw:       twManager,
+		modules:  make(map[string]*module.Module),
+		inflight: make(map[int64]struct{}),
+		cancels:  make(map[int64]context.CancelFunc),
+		hookWake: make(chan struct{}, 1),
+		running:  make(map[int64]*RunningTask),
+		names:    make(map[string]*NameStats),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 	return Manager
 }
@@ -117,10 +172,22 @@ func (m *Manager) SubscribeNewEvent(ch chan<- int64) event.Subscription {
 	return m.addScope.Track(m.addFeed.Subscribe(ch))
 }
 
+// Start opens databases, recovers tasks and starts scheduler. Manager can be
+// started again after it is stopped.
 func (m *Manager) Start() error {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.isRunning {
+		return ErrManagerRunning
+	}
+	if err := validateWebhooks(m.opts.Webhook.URLs); err != nil {
+		return err
+	}
+
 	pluginDir := filepath.Join(m.root, DefaultPluginDir)
 	if err := os.MkdirAll(pluginDir, 0700); err != nil {
 		return err
@@ -140,51 +207,159 @@ func (m *Manager) Start() error {
 	}
 	m.genID = genID
 
-	dbTaskFile := filepath.Join(m.root, "task")
-	dbTask, err := leveldb.NewLDBDatabase(dbTaskFile, 0, 0)
+	dbTask, err := m.openBackend("task")
 	if err != nil {
 		return err
 	}
-	m.dbTask = store.NewStore(dbTask, store.TaskPrefix)
-
-	dbResultFile := filepath.Join(m.root, "result")
-	dbResult, err := leveldb.NewLDBDatabase(dbResultFile, 0, 0)
+	dbResult, err := m.openBackend("result")
 	if err != nil {
+		dbTask.Close()
 		return err
 	}
+	m.dbTask = store.NewStore(dbTask, store.TaskPrefix)
+	m.dbIndex = store.NewStore(dbTask, store.IndexPrefix)
+	m.dbMeta = store.NewStore(dbTask, store.MetaPrefix)
+	m.dbAudit = store.NewStore(dbTask, store.AuditPrefix)
+	m.dbEvent = store.NewStore(dbTask, store.EventPrefix)
+	m.dbWebhook = store.NewStore(dbTask, store.WebhookPrefix)
+	m.dbQueue = store.NewStore(dbTask, store.QueuePrefix)
 	m.dbResult = store.NewStore(dbResult, store.ResultPrefix)
+	m.dbHistory = store.NewStore(dbResult, store.HistoryPrefix)
+
+	// scheduler state of previous run is dropped, tasks are recovered from database.
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.tw = tw.NewTimeWheel(m.opts.Interval, m.opts.SlotNum)
+	m.inflight = make(map[int64]struct{})
+	m.cancels = make(map[int64]context.CancelFunc)
+
+	if err := m.open(); err != nil {
+		m.cancel()
+		dbTask.Close()
+		dbResult.Close()
+		return err
+	}
+	m.openAudit()
+
+	m.wg.Add(3)
+	go m.run(m.update)
+	go m.run(m.compactLoop)
+	go m.run(m.webhookLoop)
+
+	m.isRunning = true
+	atomic.StoreInt64(&m.lastTick, time.Now().UnixNano())
+	atomic.StoreInt32(&m.ready, 1)
+	log.Info("task service is running")
+	return nil
+}
 
-	if err := m.loadModules(); err != nil {
+// open loads and repairs database, it is called by Start with opened stores.
+func (m *Manager) open() error {
+	var err error
+	if err = m.migrate(); err != nil {
+		return err
+	}
+	if err = m.loadAuditSeq(); err != nil {
+		return err
+	}
+	if m.eventSeq, err = m.loadSeq(store.EventSeqKey); err != nil {
+		return err
+	}
+	if m.hookSeq, err = m.loadSeq(store.WebhookSeqKey); err != nil {
+		return err
+	}
+	if err = m.loadModules(); err != nil {
+		return err
+	}
+	if err = m.reconcile(); err != nil {
+		return err
+	}
+	if err = m.recoverTasks(); err != nil {
 		return err
 	}
 
-	fsm, err := fs.NewEventMsg(m.ctx, m)
+	fsm, err := fs.NewEventMsgWithConfig(m.ctx, m, m.opts.Subscribe)
 	if err != nil {
 		return err
 	}
 	m.es = fsm
-
-	if err := m.filesWatcher(); err != nil {
+	if err = m.filesWatcher(); err != nil {
 		return err
 	}
-	go m.update()
-
-	m.isRunning = true
-	log.Info("task service is running")
+	m.registerGauges()
 	return nil
 }
 
+// run runs a background worker which is waited by Stop.
+func (m *Manager) run(worker func()) {
+	defer m.wg.Done()
+	worker()
+}
+
+// Stop stops scheduler and closes databases after background workers and
+// executions in progress are finished.
 func (m *Manager) Stop() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
 
-	m.cancel()
+	m.mu.Lock()
+	if !m.isRunning {
+		m.mu.Unlock()
+		return ErrManagerNotRunning
+	}
+	atomic.StoreInt32(&m.ready, 0)
 	m.isRunning = false
+	m.cancel()
+	unregisterGauges()
+	m.mu.Unlock()
+
+	// executions take m.mu between runs, so workers are waited without it.
+	m.wg.Wait()
+
+	// closed scopes track nothing, new ones are used after restart.
+	m.scope.Close()
+	m.addScope.Close()
+	m.eventScope.Close()
+	m.lifecycleScope.Close()
+	m.scope = event.SubscriptionScope{}
+	m.addScope = event.SubscriptionScope{}
+	m.eventScope = event.SubscriptionScope{}
+	m.lifecycleScope = event.SubscriptionScope{}
+
+	m.closeAudit()
+	m.dbTask.Close()
+	m.dbResult.Close()
+
 	log.Info("task service is stopped")
+	return nil
+}
 
+// IsRunning returns manager is running or not.
+func (m *Manager) IsRunning() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.isRunning
+}
+
+// checkRunning returns ErrManagerNotRunning if manager is not started, stores
+// are opened by Start and closed by Stop.
+func (m *Manager) checkRunning() error {
+	if atomic.LoadInt32(&m.ready) == 0 {
+		return ErrManagerNotRunning
+	}
 	return nil
 }
 
+// openBackend opens storage backend of the given name by engine of options.
+func (m *Manager) openBackend(name string) (store.Backend, error) {
+	file := filepath.Join(m.root, name)
+	if m.opts.Engine == store.EngineBolt {
+		file += ".db"
+	}
+	log.Infof("open %s storage %s", m.opts.Engine, file)
+	return store.Open(m.opts.Engine, file)
+}
+
 // apis returns the collection of RPC descriptors this node offers.
 func (m *Manager) APIs() []types.API {
 	return []types.API{
@@ -197,6 +372,14 @@ func (m *Manager) APIs() []types.API {
 			Version:   "1.0",
 			Service:   NewPublicTaskAPI(m),
 			Public:    true,
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateArchiveAPI(m),
+		}, {
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateAuditAPI(m),
 		},
 	}
 }
@@ -232,19 +415,19 @@ func (m *Manager) update() {
 
 	for {
 		select {
-		case job := <-m.addTask:
-			m.addHandle(&job)
-
-		case tid := <-m.deleteTask:
-			m.deleteHandle(tid)
-
 		case <-ticker.C:
-			m.mu.Lock()
+			m.lockUpdate()
 			jobs := m.tw.Trigger()
+			for _, tid := range jobs {
+				m.inflight[tid] = struct{}{}
+			}
+			m.snapshotWheel()
 			m.mu.Unlock()
+			atomic.StoreInt64(&m.lastTick, time.Now().UnixNano())
 
 			if len(jobs) > 0 {
-				go m.executeHandle(jobs)
+				m.wg.Add(1)
+				go m.run(func() { m.executeHandle(jobs) })
 			}
 
 		case ev := <-m.watchModule.Event():
@@ -263,16 +446,29 @@ func (m *Manager) update() {
 
 			switch ev.Type {
 			case EventCreated:
-				m.mu.Lock()
-				if _, ok := m.modules[id]; !ok {
+				m.lockUpdate()
+				_, ok := m.modules[id]
+				if !ok {
 					m.modules[id] = module.NewModule(file, id, version)
 				}
 				m.mu.Unlock()
+				if !ok {
+					m.publishModule(cmn.LifecycleModuleAdded, id)
+				}
+				m.audit(CallerWatcher, "", "module_created", map[string]string{"module": id, "file": ev.File}, nil)
 
 			case EventDropped:
-				m.mu.Lock()
-				delete(m.modules, id)
+				m.lockUpdate()
+				md, ok := m.modules[id]
+				ok = ok && !md.IsBuiltin()
+				if ok {
+					delete(m.modules, id)
+				}
 				m.mu.Unlock()
+				if ok {
+					m.publishModule(cmn.LifecycleModuleRemoved, id)
+				}
+				m.audit(CallerWatcher, "", "module_dropped", map[string]string{"module": id, "file": ev.File}, nil)
 			}
 
 			log.Infof("event info: %#v", ev)
@@ -291,24 +487,6 @@ func (m *Manager) filesWatcher() error {
 	return nil
 }
 
-func (m *Manager) addHandle(job *cmn.Job) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.tw.Add(job)
-
-	return nil
-}
-
-func (m *Manager) deleteHandle(tid int64) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	m.tw.Delete(tid)
-
-	return nil
-}
-
 func (m *Manager) executeHandle(jobs []int64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -317,87 +495,207 @@ func (m *Manager) executeHandle(jobs []int64) error {
 	r := m.executeJobs(jobs)
 	log.Warnf("executeJobs results: %#v\n", r)
 
+	for _, tid := range jobs {
+		delete(m.inflight, tid)
+	}
+	m.snapshotWheel()
+
 	return nil
 }
 
 func (m *Manager) executeJobs(tids []int64) error {
-	results := make([]cmn.Result, len(tids))
-	ctx := context.Background()
-
-	var job cmn.Job
-	for idx, tid := range tids {
-		jobBytes, err := m.dbTask.Get(cmn.EncodeItemID(uint64(tid)).Bytes())
+	results := make([]cmn.Result, 0, len(tids))
+	for _, tid := range tids {
+		job, err := m.getJob(uint64(tid))
 		if err != nil {
-			results[idx] = cmn.Result{
+			now := time.Now().Unix()
+			results = append(results, cmn.Result{
 				ID:        tid,
-				BeginTime: time.Now().Unix(),
-				EndTime:   time.Now().Unix(),
+				BeginTime: now,
+				EndTime:   now,
 				ErrorMsg:  err.Error(),
-			}
+			})
 			continue
 		}
-
-		if err := json.Unmarshal(jobBytes, &job); err != nil {
-			results[idx] = cmn.Result{
-				ID:        tid,
-				BeginTime: time.Now().Unix(),
-				EndTime:   time.Now().Unix(),
-				ErrorMsg:  err.Error(),
-			}
+		if job.State != cmn.JobStateScheduled {
+			log.Warnf("skip task %d in state %s", tid, job.State)
 			continue
 		}
 
 		begin := time.Now()
-		switch job.Type {
-		case cmn.JobTypeCmd:
-			output, err := cmd.ExecCmd(string(job.Extra), job.Retry)
-			results[idx] = cmn.Result{
-				ID:        tid,
-				BeginTime: begin.Unix(),
-				EndTime:   time.Now().Unix(),
-				ErrorMsg:  cmn.ToMsg(err),
-				Extra:     output,
+		r := m.executeJob(job)
+		elapsed := time.Since(begin)
+
+		// metric
+		metrics.TaskExecuteMeter.Mark(1)
+		metrics.TaskExecuteTimer.Update(elapsed)
+		outcome := metrics.OutcomeSuccess
+		if !r.Succeeded() {
+			outcome = metrics.OutcomeFailure
+		}
+		metrics.ObserveExecution(job.Type.String(), outcome, elapsed)
+
+		if err := m.saveResult(r); err != nil {
+			log.Errorf("db put result error, %#v, %v", r, err)
+		}
+		m.enqueueWebhooks(job, r)
+		m.recordRun(job.Name, r)
+		results = append(results, *r)
+	}
+
+	log.Debugf("results: %#v", results)
+	m.appendResultEvents(results)
+	m.resultsFeed.Send(results)
+	return nil
+}
+
+// executeJob runs job up to its retry times and moves it to its final state.
+// Job fired after its limit time is expired and not run, job cancelled while
+// it is running is not retried.
+func (m *Manager) executeJob(job *cmn.Job) *cmn.Result {
+	tid := job.UUID.Int64()
+	begin := time.Now()
+	defer m.clearRunning(tid)
+
+	if job.LimitTime > 0 && begin.Unix() > job.LimitTime {
+		if err := m.transit(job, cmn.JobStateExpired); err != nil {
+			log.Errorf("db put state error, %d, %v", tid, err)
+		}
+		m.publishTask(cmn.LifecycleExpired, job, 0, cmn.ErrTaskExpired)
+		return &cmn.Result{
+			ID:        tid,
+			Name:      job.Name,
+			Type:      job.Type,
+			BeginTime: begin.Unix(),
+			EndTime:   begin.Unix(),
+			ErrorMsg:  cmn.ToMsg(cmn.ErrTaskExpired),
+		}
+	}
+
+	var (
+		output []byte
+		err    error
+	)
+	for attempt := 1; ; attempt++ {
+		if terr := m.transit(job, cmn.JobStateRunning); terr != nil {
+			log.Errorf("db put state error, %d, %v", tid, terr)
+		}
+		m.setRunning(job, attempt)
+		m.publishTask(cmn.LifecycleStarted, job, attempt, nil)
+		output, err = m.runJob(job)
+		if m.isCancelled(job) {
+			err = cmn.ErrTaskCancelled
+			break
+		}
+		if isTimeout(err) {
+			m.publishTask(cmn.LifecycleTimedOut, job, attempt, err)
+		}
+		if err == nil {
+			if terr := m.transit(job, cmn.JobStateSucceeded); terr != nil {
+				log.Errorf("db put state error, %d, %v", tid, terr)
 			}
-		case cmn.JobTypeFile:
-			cmdFile := filepath.Join(m.cmdRoot, fmt.Sprintf("%v.sh", tid))
-			log.Debugf("cmd file:%s", cmdFile)
-			output, err := cmd.ExecCmdFile(cmdFile, job.Retry)
-			results[idx] = cmn.Result{
-				ID:        tid,
-				BeginTime: begin.Unix(),
-				EndTime:   time.Now().Unix(),
-				ErrorMsg:  cmn.ToMsg(err),
-				Extra:     output,
+			break
+		}
+		if attempt >= job.Retry {
+			if terr := m.transit(job, cmn.JobStateFailed); terr != nil {
+				log.Errorf("db put state error, %d, %v", tid, terr)
 			}
+			break
+		}
 
-		case cmn.JobTypePlugin:
-			if m := m.modules[string(job.Extra)]; m != nil {
-				err := m.ExecuteWithRetry(ctx, job.Retry)
-				results[idx] = cmn.Result{
-					ID:        tid,
-					BeginTime: begin.Unix(),
-					EndTime:   time.Now().Unix(),
-					ErrorMsg:  cmn.ToMsg(err),
-				}
-			}
+		log.Errorf("index: %d execute task: %d error: %v", attempt, tid, err)
+		if terr := m.transit(job, cmn.JobStateRetrying); terr != nil {
+			log.Errorf("db put state error, %d, %v", tid, terr)
 		}
+		m.publishTask(cmn.LifecycleRetrying, job, attempt, err)
+	}
 
-		// metric
-		metrics.TaskExecuteMeter.Mark(1)
-		metrics.TaskExecuteTimer.Update(time.Duration(results[idx].EndTime-results[idx].BeginTime) * time.Second)
+	return &cmn.Result{
+		ID:        tid,
+		Name:      job.Name,
+		Type:      job.Type,
+		BeginTime: begin.Unix(),
+		EndTime:   time.Now().Unix(),
+		ErrorMsg:  cmn.ToMsg(err),
+		Extra:     output,
+	}
+}
 
-		jsonBytes, err := json.Marshal(results[idx])
+// runJob runs job once, m.mu should be held. The lock is released while job is
+// running, meanwhile running job can only be cancelled by DeleteTask. Run is
+// stopped when it is cancelled or exceeds ExecTimeout of options.
+func (m *Manager) runJob(job *cmn.Job) ([]byte, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if m.opts.ExecTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), m.opts.ExecTimeout)
+	}
+	defer cancel()
+
+	var run func() ([]byte, error)
+	switch job.Type {
+	case cmn.JobTypeCmd:
+		run = func() ([]byte, error) { return cmd.ExecCmd(string(job.Extra), 1) }
+
+	case cmn.JobTypeFile:
+		cmdFile, err := m.ensureScript(job)
 		if err != nil {
-			log.Errorf("json marshal struct of result error, %#v, %v", results[idx], err)
+			return nil, err
 		}
-		if err := m.dbResult.Put(cmn.EncodeItemID(uint64(tid)).Bytes(), jsonBytes); err != nil {
-			log.Errorf("db put result error, %#v, %v", results[idx], err)
+		log.Debugf("cmd file:%s", cmdFile)
+		run = func() ([]byte, error) { return cmd.ExecCmdFile(cmdFile, 1) }
+
+	case cmn.JobTypePlugin:
+		md := m.modules[moduleID(string(job.Extra))]
+		if md == nil {
+			return nil, cmn.ErrInvalidPluginName
 		}
+		j := *job
+		jobCtx := cmn.WithJob(ctx, &j)
+		run = func() ([]byte, error) { return nil, md.Execute(jobCtx) }
+
+	default:
+		return nil, cmn.ErrInvalidJobType
 	}
 
-	log.Debugf("results: %#v", results)
-	m.resultsFeed.Send(results)
-	return nil
+	tid := job.UUID.Int64()
+	m.cancels[tid] = cancel
+	defer delete(m.cancels, tid)
+
+	m.mu.Unlock()
+	defer m.mu.Lock()
+	return runContext(ctx, run)
+}
+
+// runContext runs fn until it returns or ctx is done. Plugin gets ctx, command
+// can not be stopped, it is left running and its output is dropped.
+func runContext(ctx context.Context, fn func() ([]byte, error)) ([]byte, error) {
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := fn()
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// isCancelled returns whether running job is cancelled by DeleteTask while the
+// lock is released, m.mu should be held.
+func (m *Manager) isCancelled(job *cmn.Job) bool {
+	stored, err := m.getJob(job.UUID.Uint64())
+	if err != nil {
+		log.Errorf("get job error, %d, %v", job.UUID.Int64(), err)
+		return false
+	}
+	return stored.State == cmn.JobStateCancelled
 }
 
 // ListModules lists loaded module.
@@ -412,6 +710,58 @@ func (m *Manager) ListModules() []string {
 	return ms
 }
 
+// RegisterHandler registers an in-process handler as module, id is like name@version,
+// default version is used if no version in id. Plugin jobs can target it like so file.
+func (m *Manager) RegisterHandler(id string, handle func(ctx context.Context) error) error {
+	return m.RegisterHandlerWithErr(id, handle, nil)
+}
+
+// RegisterHandlerWithErr registers an in-process handler with error handler as module.
+func (m *Manager) RegisterHandlerWithErr(id string, handle func(ctx context.Context) error,
+	errHandle func(ctx context.Context, err error)) (err error) {
+	defer func() {
+		m.Audit(context.Background(), "module_register", map[string]string{"module": id}, err)
+	}()
+
+	if handle == nil || id == "" {
+		return cmn.ErrInvalidParameter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id = moduleID(id)
+	if _, ok := m.modules[id]; ok {
+		return cmn.ErrDuplicateModule
+	}
+	name, version := splitModuleID(id)
+	m.modules[id] = module.NewModuleWithFuncs(name, version, handle, errHandle)
+	log.Infof("register handler module %s", id)
+	m.publishModule(cmn.LifecycleModuleAdded, id)
+	return nil
+}
+
+// UnregisterHandler removes an in-process handler registered by RegisterHandler.
+func (m *Manager) UnregisterHandler(id string) bool {
+	m.mu.Lock()
+	id = moduleID(id)
+	md, ok := m.modules[id]
+	ok = ok && md.IsBuiltin()
+	if ok {
+		delete(m.modules, id)
+	}
+	m.mu.Unlock()
+
+	var err error
+	if ok {
+		m.publishModule(cmn.LifecycleModuleRemoved, id)
+	} else {
+		err = ErrModuleNotFound
+	}
+	m.Audit(context.Background(), "module_unregister", map[string]string{"module": id}, err)
+	return ok
+}
+
 // ListModules lists loaded module.
 func (m *Manager) CheckModule(id string) (bool, error) {
 	m.mu.RLock()
@@ -428,13 +778,24 @@ func (m *Manager) AddTask(job *cmn.Job) (int64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.checkRunning(); err != nil {
+		return 0, err
+	}
+
 	log.Debugf("job info %#v, %s", job, string(job.Extra))
 
+	if err := validateWebhooks(job.Webhooks); err != nil {
+		return 0, err
+	}
+
 	newID, err := m.genID.NextId()
 	if err != nil {
 		return 0, err
 	}
 	job.UUID = cmn.EncodeItemID(uint64(newID))
+	if job.AddTime == 0 {
+		job.AddTime = time.Now().Unix()
+	}
 
 	switch job.Type {
 	case cmn.JobTypeCmd:
@@ -445,56 +806,136 @@ func (m *Manager) AddTask(job *cmn.Job) (int64, error) {
 			return 0, cmn.ErrInvalidParameter
 		}
 
-		cmdFile := filepath.Join(m.cmdRoot, fmt.Sprintf("%v.sh", newID))
-		if err := ioutil.WriteFile(cmdFile, job.Extra, 0755); err != nil {
-			log.Errorf("write cmd file error, %s: %v", cmdFile, err)
-			return 0, err
-		}
-
 	case cmn.JobTypePlugin:
-		taskName := string(job.Extra)
-		versions := strings.Split(string(job.Extra), "@")
-		if len(versions) == 1 {
-			taskName = string(job.Extra) + "@" + DefaultVersion
-		}
+		taskName := moduleID(string(job.Extra))
 		if _, ok := m.modules[taskName]; !ok {
 			log.Errorf("plugin name error, %s: %#v", taskName, m.modules)
 			return 0, cmn.ErrInvalidPluginName
 		}
+		job.Extra = []byte(taskName)
 	}
 
-	jobBytes, err := json.Marshal(job)
-	if err != nil {
+	// task record with script content is written first, script file is a copy
+	// of it and is written again before execution or by reconciler if lost.
+	job.State = cmn.JobStateUnknown
+	if err := job.Transit(cmn.JobStateScheduled, time.Now().Unix()); err != nil {
 		return 0, err
 	}
-
-	if err := m.dbTask.Put(job.UUID.Bytes(), jobBytes); err != nil {
+	b := m.dbTask.NewBatch()
+	if err := m.putJob(b, job); err != nil {
+		return 0, err
+	}
+	if err := b.Write(); err != nil {
 		return 0, err
 	}
+	if job.Type == cmn.JobTypeFile {
+		if err := m.writeScript(job); err != nil {
+			log.Errorf("write cmd file error, %d: %v", newID, err)
+		}
+	}
 	m.tw.Add(job)
+	m.appendEvents([]cmn.Event{{Type: cmn.EventNewTask, ID: newID}})
 	m.addFeed.Send(newID)
 
 	return job.UUID.Int64(), nil
 }
 
-// AddTask add delay task.
+// DeleteTask cancels task, record of cancelled task is kept until it is compacted.
 func (m *Manager) DeleteTask(job *cmn.Job) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if err := m.checkRunning(); err != nil {
+		return err
+	}
+
 	log.Debugf("job info %#v, %s", job, string(job.Extra))
 
-	if err := m.dbTask.Delete(job.UUID.Bytes()); err != nil {
+	stored, err := m.getJob(job.UUID.Uint64())
+	if err != nil {
 		return err
 	}
-
-	isDelete := m.tw.Delete(job.UUID.Int64())
-	if isDelete {
-		cmdFile := filepath.Join(m.cmdRoot, fmt.Sprintf("%v.sh", job.UUID))
-		if common.FileExist(cmdFile) {
-			return common.RemoveFile(cmdFile)
+	id := stored.UUID.Int64()
+
+	// finished task is removed with its indexes and results, live task is
+	// cancelled and its record is kept until it is compacted. Cancelled task
+	// is removed after its run is finished.
+	if stored.State.IsTerminal() {
+		if m.isInflight(id) {
+			return cmn.NewConflictError("task is being executed",
+				&cmn.ErrorDetail{Kind: "task", ID: id, State: stored.State.String()})
+		}
+		b := m.dbTask.NewBatch()
+		if err := m.deleteJob(b, stored); err != nil {
+			return err
+		}
+		if err := b.Write(); err != nil {
+			return err
+		}
+		if err := m.deleteResults(stored.UUID.Uint64()); err != nil {
+			return err
 		}
+		m.publishTask(cmn.LifecycleDeleted, stored, 0, nil)
+		return m.removeScript(id)
+	}
+
+	if err := m.transit(stored, cmn.JobStateCancelled); err != nil {
+		return err
+	}
+	m.publishTask(cmn.LifecycleCancelled, stored, 0, nil)
+	if cancel := m.cancels[id]; cancel != nil {
+		cancel()
 	}
+
+	// script file is removed even if task is not in time wheel, orphaned
+	// scripts left by a crash here are removed by compactor.
+	m.tw.Delete(id)
+	return m.removeScript(id)
+}
+
+// Delete deletes job by id.
+func (m *Manager) Delete(id int64) error {
+	return m.DeleteTask(&cmn.Job{UUID: cmn.EncodeItemID(uint64(id))})
+}
+
+// PauseTask removes scheduled task from time wheel until it is resumed.
+func (m *Manager) PauseTask(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRunning(); err != nil {
+		return err
+	}
+
+	job, err := m.getJob(uint64(id))
+	if err != nil {
+		return err
+	}
+	if err := m.transit(job, cmn.JobStatePaused); err != nil {
+		return err
+	}
+	m.tw.Delete(id)
+	return nil
+}
+
+// ResumeTask adds paused task into time wheel again. Task keeps its fire time,
+// it is fired at next tick if the time is passed.
+func (m *Manager) ResumeTask(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRunning(); err != nil {
+		return err
+	}
+
+	job, err := m.getJob(uint64(id))
+	if err != nil {
+		return err
+	}
+	if err := m.transit(job, cmn.JobStateScheduled); err != nil {
+		return err
+	}
+	m.schedule(job)
 	return nil
 }
 
@@ -503,26 +944,77 @@ func (m *Manager) GetTask(job *cmn.Job) (map[string]interface{}, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
 	log.Debugf("job info %#v, %s", job, string(job.Extra))
 
-	jobBytes, err := m.dbTask.Get(job.UUID.Bytes())
+	stored, err := m.getJob(job.UUID.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	jobBytes, err := json.Marshal(stored)
 	if err != nil {
 		return nil, err
 	}
 	idx, circle := m.tw.Get(job.UUID.Int64())
 
 	return map[string]interface{}{
-		"info":   string(jobBytes),
-		"index":  idx,
-		"circle": circle,
+		"info":       string(jobBytes),
+		"state":      stored.State,
+		"state_time": stored.StateTime,
+		"index":      idx,
+		"circle":     circle,
 	}, nil
 }
 
+// GetTaskV2 returns task by id with its next fire time and position in time wheel.
+func (m *Manager) GetTaskV2(id int64) (*cmn.TaskDetail, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	job, err := m.getJob(uint64(id))
+	if err != nil {
+		return nil, err
+	}
+
+	d := cmn.NewTaskDetail(job)
+	if m.tw.Check(id) {
+		d.Scheduled = true
+		d.Index, d.Circle = m.tw.Get(id)
+	}
+	if job.State == cmn.JobStateScheduled {
+		d.NextFire = nextFire(job)
+	}
+	return d, nil
+}
+
+// Job returns job by id.
+func (m *Manager) Job(id int64) (*cmn.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	return m.getJob(uint64(id))
+}
+
 // AddTask add delay task.
 func (m *Manager) CheckTask(job *cmn.Job) (bool, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if err := m.checkRunning(); err != nil {
+		return false, err
+	}
+
 	log.Debugf("job info %#v, %s", job, string(job.Extra))
 
 	if _, err := m.dbTask.Get(job.UUID.Bytes()); err != nil {
@@ -536,6 +1028,10 @@ func (m *Manager) GetResult(job *cmn.Job) (map[string]interface{}, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
 	log.Debugf("job info %#v, %s", job, string(job.Extra))
 
 	jobBytes, err := m.dbResult.Get(job.UUID.Bytes())
@@ -547,6 +1043,53 @@ func (m *Manager) GetResult(job *cmn.Job) (map[string]interface{}, error) {
 	}, nil
 }
 
+// GetResultV2 returns last result of task by id with decoded output.
+func (m *Manager) GetResultV2(id int64) (*cmn.ResultDetail, error) {
+	r, err := m.Result(id)
+	if err != nil {
+		return nil, err
+	}
+	return cmn.NewResultDetail(r), nil
+}
+
+// Result returns last result of job by id.
+func (m *Manager) Result(id int64) (*cmn.Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	resultBytes, err := m.dbResult.Get(cmn.EncodeItemID(uint64(id)).Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(cmn.Result)
+	if err := json.Unmarshal(resultBytes, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// moduleID returns id of module as name@version.
+func moduleID(id string) string {
+	if versions := strings.Split(id, "@"); len(versions) == 1 {
+		return id + "@" + DefaultVersion
+	}
+	return id
+}
+
+// splitModuleID returns name and version of module id.
+func splitModuleID(id string) (string, string) {
+	idx := strings.LastIndex(id, "@")
+	if idx < 0 {
+		return id, DefaultVersion
+	}
+	return id[:idx], id[idx+1:]
+}
+
 func parseModuleName(file string) (string, string) {
 	fileName := []byte(file)
 	var name, version string
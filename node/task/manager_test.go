@@ -0,0 +1,226 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// putTestJob writes job with its indexes.
+func putTestJob(t *testing.T, m *Manager, job *cmn.Job) {
+	b := m.dbTask.NewBatch()
+	if err := m.putJob(b, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Write(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// history returns keys of runs of task.
+func (m *Manager) history(t *testing.T, id uint64) [][]byte {
+	var keys [][]byte
+	err := m.dbHistory.Iterate(cmn.EncodeItemID(id).Bytes(), nil, func(key, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestDeleteFinishedTask(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+
+	job := &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1), State: cmn.JobStateSucceeded}
+	putTestJob(t, m, job)
+	for i := 0; i < 2; i++ {
+		if err := m.saveResult(&cmn.Result{ID: 1, ErrorMsg: cmn.ToMsg(nil)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// finished task is removed with its results, deleting it again is not found.
+	if err := m.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.getJob(1); err != store.ErrNotFound {
+		t.Fatalf("get deleted task: %v", err)
+	}
+	if _, err := m.Result(1); err != store.ErrNotFound {
+		t.Fatalf("get result of deleted task: %v", err)
+	}
+	if n := len(m.history(t, 1)); n != 0 {
+		t.Fatalf("runs of deleted task: %d", n)
+	}
+	if ids, _ := m.jobsInState(cmn.JobStateSucceeded); len(ids) != 0 {
+		t.Fatalf("state index of deleted task: %v", ids)
+	}
+	if err := m.Delete(1); err != store.ErrNotFound {
+		t.Fatalf("delete deleted task: %v", err)
+	}
+
+	// live task is cancelled and kept.
+	job = &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2), State: cmn.JobStateScheduled}
+	putTestJob(t, m, job)
+	m.tw.Add(job)
+	if err := m.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := m.getJob(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != cmn.JobStateCancelled || m.tw.Check(2) {
+		t.Fatalf("cancelled task: %s, in wheel %v", stored.State, m.tw.Check(2))
+	}
+}
+
+func TestRestart(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(&Options{DataDir: dir, NodeID: "1", Engine: store.EngineBolt, Interval: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.opts.Retention.EventMaxAge != DefaultEventMaxAge {
+		t.Fatalf("event max age: %v, want %v", m.opts.Retention.EventMaxAge, DefaultEventMaxAge)
+	}
+	if err := m.Stop(); err != ErrManagerNotRunning {
+		t.Fatalf("stop manager not started: %v", err)
+	}
+
+	var id int64
+	for i := 0; i < 2; i++ {
+		if err := m.Start(); err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		if i == 0 {
+			job := &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, Retry: 1, Interval: 3600, Extra: []byte("true")}
+			if id, err = m.AddTask(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// wheel ticks after every start and task is recovered.
+		tick := atomic.LoadInt64(&m.lastTick)
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadInt64(&m.lastTick) == tick {
+			if time.Now().After(deadline) {
+				t.Fatalf("start %d: wheel does not tick", i)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if h := m.Ready(); !h.OK() {
+			t.Fatalf("start %d: not ready, %#v", i, h)
+		}
+		if _, err := m.Job(id); err != nil {
+			t.Fatalf("start %d: %v", i, err)
+		}
+		if err := m.Stop(); err != nil {
+			t.Fatalf("stop %d: %v", i, err)
+		}
+	}
+}
+
+func TestSaveResult(t *testing.T) {
+	m := newTestManager()
+	for i := 0; i < 3; i++ {
+		if err := m.saveResult(&cmn.Result{ID: 1, EndTime: int64(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// last result is the last run in history.
+	r, err := m.Result(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := m.history(t, 1)
+	if r.Run != 3 || r.EndTime != 2 || len(keys) != 3 || string(keys[2]) != string(store.RunKey(1, 3)) {
+		t.Fatalf("last result: %#v, runs: %x", r, keys)
+	}
+}
+
+func TestDeleteRunningTask(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	cancelled := make(chan error, 1)
+	err := m.RegisterHandler("block", func(ctx context.Context) error {
+		close(started)
+		<-release
+		cancelled <- ctx.Err()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putTestJob(t, m, &cmn.Job{Name: "dev", Type: cmn.JobTypePlugin, UUID: cmn.EncodeItemID(1),
+		Extra: []byte("block"), Retry: 3, AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled})
+	m.inflight[1] = struct{}{}
+
+	done := make(chan struct{})
+	go func() {
+		m.executeHandle([]int64{1})
+		close(done)
+	}()
+	<-started
+
+	if err := m.Delete(1); err != nil {
+		t.Fatalf("delete running task: %v", err)
+	}
+	// run is stopped without waiting for handler.
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run of cancelled task is waited")
+	}
+	close(release)
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("context of run: %v, want %v", err, context.Canceled)
+	}
+	job, err := m.getJob(1)
+	if err != nil || job.State != cmn.JobStateCancelled {
+		t.Fatalf("task after run: %#v, %v", job, err)
+	}
+	r, err := m.Result(1)
+	if err != nil || r.ErrorMsg != cmn.ToMsg(cmn.ErrTaskCancelled) || len(m.history(t, 1)) != 1 {
+		t.Fatalf("result of cancelled run: %#v, %v", r, err)
+	}
+
+	// cancelled task is not removed until its run is finished.
+	m.inflight[1] = struct{}{}
+	if err := m.Delete(1); cmn.ErrorCodeOf(err) != cmn.CodeConflict {
+		t.Fatalf("delete cancelled task being executed: %v, want conflict", err)
+	}
+	delete(m.inflight, 1)
+
+	if err := m.Delete(1); err != nil {
+		t.Fatalf("delete cancelled task: %v", err)
+	}
+	if _, err := m.getJob(1); err != store.ErrNotFound {
+		t.Fatalf("deleted task: %v", err)
+	}
+}
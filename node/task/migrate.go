@@ -0,0 +1,232 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// ErrSchemaTooNew is returned if database is written by a newer version.
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// migration upgrades database from version-1 to version, it must be idempotent
+// as it runs again if process exits before version is written.
+type migration struct {
+	version int
+	name    string
+	upgrade func(m *Manager) error
+}
+
+// migrations is all migrations ordered by version. Database without version
+// key is version 0, which is the format before versioning.
+var migrations = []migration{
+	{1, "add run number to results", migrateResultRuns},
+	{2, "persist job state and rebuild indexes", migrateJobStates},
+}
+
+// SchemaVersion is version of database written by this version.
+var SchemaVersion = migrations[len(migrations)-1].version
+
+// schemaVersion returns version of database.
+func (m *Manager) schemaVersion() (int, error) {
+	value, err := m.dbMeta.Get(store.SchemaVersionKey)
+	if err == store.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("invalid schema version %x", value)
+	}
+	return int(binary.BigEndian.Uint64(value)), nil
+}
+
+// setSchemaVersion writes version of database.
+func (m *Manager) setSchemaVersion(version int) error {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(version))
+	return m.dbMeta.Put(store.SchemaVersionKey, value)
+}
+
+// migrate upgrades database to SchemaVersion, version is written after each migration.
+func (m *Manager) migrate() error {
+	version, err := m.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("%v: %d > %d", ErrSchemaTooNew, version, SchemaVersion)
+	}
+
+	for _, mg := range migrations {
+		if mg.version <= version {
+			continue
+		}
+		log.Infof("migrate database to version %d: %s", mg.version, mg.name)
+		if err := mg.upgrade(m); err != nil {
+			return fmt.Errorf("migrate database to version %d error: %v", mg.version, err)
+		}
+		if err := m.setSchemaVersion(mg.version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateResultRuns numbers last result of each task as its first run and
+// copies it into history store.
+func migrateResultRuns(m *Manager) error {
+	var results []*cmn.Result
+	err := m.dbResult.Iterate(nil, nil, func(key, value []byte) bool {
+		r := new(cmn.Result)
+		if err := json.Unmarshal(value, r); err != nil {
+			log.Errorf("json unmarshal result error, %x, %v", key, err)
+			return true
+		}
+		if r.Run == 0 {
+			r.ID = int64(binary.BigEndian.Uint64(key))
+			results = append(results, r)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		r.Run = 1
+		jsonBytes, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		b := m.dbResult.NewBatch()
+		if err := b.Put(m.dbHistory, store.RunKey(uint64(r.ID), r.Run), jsonBytes); err != nil {
+			return err
+		}
+		if err := b.Put(m.dbResult, cmn.EncodeItemID(uint64(r.ID)).Bytes(), jsonBytes); err != nil {
+			return err
+		}
+		if err := b.Write(); err != nil {
+			return err
+		}
+	}
+	log.Infof("migrate results: %d", len(results))
+	return nil
+}
+
+// migrateJobStates writes state of jobs which have no state, and rebuilds all
+// indexes. Job with a result is finished by the result, job which is running
+// in an old state index is left running for reconciler, job whose fire time
+// has passed is expired, others are scheduled.
+func migrateJobStates(m *Manager) error {
+	var (
+		jobs      []*cmn.Job
+		indexKeys [][]byte
+	)
+	err := m.dbTask.Iterate(nil, nil, func(key, value []byte) bool {
+		job := new(cmn.Job)
+		if err := json.Unmarshal(value, job); err != nil {
+			log.Errorf("json unmarshal job error, %x, %v", key, err)
+			return true
+		}
+		job.UUID = cmn.EncodeItemID(binary.BigEndian.Uint64(key))
+		jobs = append(jobs, job)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	err = m.dbIndex.Iterate(nil, nil, func(key, value []byte) bool {
+		indexKeys = append(indexKeys, key)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if job.State != cmn.JobStateUnknown {
+			continue
+		}
+		if err := migrateJobState(m, job); err != nil {
+			return err
+		}
+	}
+
+	b := m.dbTask.NewBatch()
+	for _, key := range indexKeys {
+		if err := b.Delete(m.dbIndex, key); err != nil {
+			return err
+		}
+	}
+	if err := b.Write(); err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		b := m.dbTask.NewBatch()
+		if err := m.putJob(b, job); err != nil {
+			return err
+		}
+		if err := b.Write(); err != nil {
+			return err
+		}
+	}
+	log.Infof("migrate jobs: %d, indexes: %d", len(jobs), len(indexKeys))
+	return nil
+}
+
+// migrateJobState sets state of job without state.
+func migrateJobState(m *Manager, job *cmn.Job) error {
+	id := job.UUID.Uint64()
+
+	resultBytes, err := m.dbResult.Get(job.UUID.Bytes())
+	switch {
+	case err == nil:
+		var r cmn.Result
+		if err := json.Unmarshal(resultBytes, &r); err != nil {
+			return err
+		}
+		job.State = cmn.JobStateFailed
+		if r.ErrorMsg == cmn.ToMsg(nil) {
+			job.State = cmn.JobStateSucceeded
+		}
+		job.StateTime = r.EndTime
+		return nil
+	case err != store.ErrNotFound:
+		return err
+	}
+
+	job.State = cmn.JobStateScheduled
+	job.StateTime = job.AddTime
+	if ok, err := m.dbIndex.Has(store.StateIndexKey(cmn.JobStateRunning.String(), id)); err != nil {
+		return err
+	} else if ok {
+		job.State = cmn.JobStateRunning
+	} else if now := time.Now().Unix(); nextFire(job) < now {
+		job.State = cmn.JobStateExpired
+		job.StateTime = now
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+// newTestManager returns manager on memory stores, it is not started.
+func newTestManager() *Manager {
+	m := NewManagerWithOptions(&localBackend{dataDir: "."}, DefaultOptions("."))
+	dbTask, dbResult := store.NewMemory(), store.NewMemory()
+	m.dbTask = store.NewStore(dbTask, store.TaskPrefix)
+	m.dbIndex = store.NewStore(dbTask, store.IndexPrefix)
+	m.dbMeta = store.NewStore(dbTask, store.MetaPrefix)
+	m.dbAudit = store.NewStore(dbTask, store.AuditPrefix)
+	m.dbEvent = store.NewStore(dbTask, store.EventPrefix)
+	m.dbWebhook = store.NewStore(dbTask, store.WebhookPrefix)
+	m.dbQueue = store.NewStore(dbTask, store.QueuePrefix)
+	m.dbResult = store.NewStore(dbResult, store.ResultPrefix)
+	m.dbHistory = store.NewStore(dbResult, store.HistoryPrefix)
+	m.ready = 1
+	return m
+}
+
+// putFixture writes record in the format before schema versioning.
+func putFixture(t *testing.T, s *store.Store, key []byte, value string) {
+	if err := s.Put(key, []byte(value)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func jobFixture(id uint64, addTime int64) string {
+	return fmt.Sprintf(`{"name":"dev","type":"cmd","uuid":"0x%016x","retry":1,"interval":50,`+
+		`"add_time":%d,"limit_time":0,"extra":"0x6c73202d6c202f746d70"}`, id, addTime)
+}
+
+func resultFixture(id uint64, end int64, msg string) string {
+	return fmt.Sprintf(`{"id":%d,"begin_time":%d,"end_time":%d,"error":"%s","output":"0x"}`,
+		id, end-1, end, msg)
+}
+
+func TestMigrateFromUnversioned(t *testing.T) {
+	m := newTestManager()
+	now := time.Now().Unix()
+
+	// 1: waiting, 2: succeeded, 3: failed, 4: running in old state index,
+	// 5: fire time passed
+	putFixture(t, m.dbTask, cmn.EncodeItemID(1).Bytes(), jobFixture(1, now-10))
+	for id := uint64(2); id <= 5; id++ {
+		putFixture(t, m.dbTask, cmn.EncodeItemID(id).Bytes(), jobFixture(id, now-100))
+	}
+	putFixture(t, m.dbResult, cmn.EncodeItemID(2).Bytes(), resultFixture(2, now-40, "success"))
+	putFixture(t, m.dbResult, cmn.EncodeItemID(3).Bytes(), resultFixture(3, now-30, "exit status 1"))
+	putFixture(t, m.dbIndex, store.StateIndexKey("running", 4), "")
+	putFixture(t, m.dbIndex, store.StateIndexKey("finished", 9), "")
+
+	if err := m.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := m.schemaVersion(); err != nil || version != SchemaVersion {
+		t.Fatalf("schema version: %d, %v", version, err)
+	}
+
+	states := map[uint64]cmn.JobState{
+		1: cmn.JobStateScheduled,
+		2: cmn.JobStateSucceeded,
+		3: cmn.JobStateFailed,
+		4: cmn.JobStateRunning,
+		5: cmn.JobStateExpired,
+	}
+	for id, state := range states {
+		job, err := m.getJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != state || job.StateTime == 0 {
+			t.Fatalf("job %d state: %s, %d", id, job.State, job.StateTime)
+		}
+		if ok, _ := m.dbIndex.Has(store.StateIndexKey(state.String(), id)); !ok {
+			t.Fatalf("job %d has no state index", id)
+		}
+		if ok, _ := m.dbIndex.Has(store.NameIndexKey("dev", id)); !ok {
+			t.Fatalf("job %d has no name index", id)
+		}
+	}
+	if ok, _ := m.dbIndex.Has(store.StateIndexKey("finished", 9)); ok {
+		t.Fatal("old state index is not removed")
+	}
+
+	page, err := m.ListResults(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 1 || page.Results[0].Run != 1 || page.Results[0].ID != 2 {
+		t.Fatalf("results: %#v", page.Results)
+	}
+	r, err := m.Result(3)
+	if err != nil || r.Run != 1 {
+		t.Fatalf("last result: %#v, %v", r, err)
+	}
+
+	// migrated database is not changed again
+	if err := m.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := m.ListResults(2, nil); len(page.Results) != 1 {
+		t.Fatalf("results after second migration: %d", len(page.Results))
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	m := newTestManager()
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(SchemaVersion+1))
+	putFixture(t, m.dbMeta, store.SchemaVersionKey, string(value))
+
+	if err := m.migrate(); err == nil {
+		t.Fatal("newer schema is migrated")
+	}
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	m := newTestManager()
+	if err := m.migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := m.schemaVersion(); version != SchemaVersion {
+		t.Fatalf("schema version: %d", version)
+	}
+}
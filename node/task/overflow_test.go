@@ -0,0 +1,83 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"airman.com/airfk/pkg/server"
+
+	fs "airman.com/airtask/node/subscribe"
+)
+
+type testNotifier struct {
+	notices []interface{}
+}
+
+func (n *testNotifier) Notify(id server.ID, data interface{}) error {
+	n.notices = append(n.notices, data)
+	return nil
+}
+
+func TestNotifyOverflowDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := newTestManager()
+	es, err := fs.NewEventMsgWithConfig(ctx, m, fs.Config{BufferSize: 1, Policy: fs.PolicyDisconnect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adds := make(chan int64)
+	sub := es.SubscribeNewTask(adds)
+	for i := int64(1); i <= 3; i++ {
+		m.addFeed.Send(i)
+	}
+	select {
+	case <-sub.Overflow():
+	case <-time.After(5 * time.Second):
+		t.Fatal("overflow is not signaled")
+	}
+
+	n := &testNotifier{}
+	if !notifyOverflow(n, &server.Subscription{ID: "0x1"}, sub) {
+		t.Fatal("subscriber is not disconnected")
+	}
+	select {
+	case <-sub.Err():
+	default:
+		t.Fatal("subscription of disconnected subscriber is not ended")
+	}
+
+	if len(n.notices) != 1 {
+		t.Fatalf("notices: %v", n.notices)
+	}
+	data, _ := json.Marshal(n.notices[0])
+	var notice struct {
+		Overflow fs.Overflow `json:"overflow"`
+		Error    string      `json:"error"`
+	}
+	if err := json.Unmarshal(data, &notice); err != nil {
+		t.Fatal(err)
+	}
+	if !notice.Overflow.Disconnected || notice.Overflow.Policy != fs.PolicyDisconnect ||
+		notice.Overflow.Dropped == 0 || notice.Error != fs.ErrDisconnected.Error() {
+		t.Fatalf("notice %s", data)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+// ReconcileStats is what is repaired by reconciler.
+type ReconcileStats struct {
+	Scripts     int `json:"scripts"`     // orphaned script files removed
+	Restored    int `json:"restored"`    // lost script files written again
+	Indexes     int `json:"indexes"`     // dangling or stale index entries removed
+	Missing     int `json:"missing"`     // missing index entries written again
+	Interrupted int `json:"interrupted"` // running tasks marked as failed
+}
+
+// scriptFile returns script file of task.
+func (m *Manager) scriptFile(id int64) string {
+	return filepath.Join(m.cmdRoot, fmt.Sprintf("%v.sh", id))
+}
+
+// writeScript writes script file of task from its content in task record.
+// File is written to a temporary file and renamed, so it is never partial.
+func (m *Manager) writeScript(job *cmn.Job) error {
+	if len(job.Extra) == 0 {
+		return cmn.ErrInvalidParameter
+	}
+	file := m.scriptFile(job.UUID.Int64())
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, job.Extra, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// ensureScript returns script file of task, it is written again if it is lost.
+func (m *Manager) ensureScript(job *cmn.Job) (string, error) {
+	file := m.scriptFile(job.UUID.Int64())
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	}
+	log.Warnf("script file of task %d is lost, write it again", job.UUID.Int64())
+	if err := m.writeScript(job); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// removeScript removes script file of task if it exists.
+func (m *Manager) removeScript(id int64) error {
+	if err := os.Remove(m.scriptFile(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// reconcile repairs inconsistency between task store, indexes and script files
+// which is left by a crash. It is called on start before tasks are recovered.
+func (m *Manager) reconcile() error {
+	stats := &ReconcileStats{}
+
+	if err := m.reconcileIndexes(stats); err != nil {
+		return err
+	}
+	if err := m.reconcileInterrupted(stats); err != nil {
+		return err
+	}
+	if err := m.reconcileScripts(stats); err != nil {
+		return err
+	}
+
+	log.Infof("reconcile scripts: %d, restored: %d, indexes: %d, missing: %d, interrupted: %d",
+		stats.Scripts, stats.Restored, stats.Indexes, stats.Missing, stats.Interrupted)
+	return nil
+}
+
+// reconcileIndexes makes indexes match task records, index entries which are
+// not of any task record are removed, and missing ones are written again.
+func (m *Manager) reconcileIndexes(stats *ReconcileStats) error {
+	expected := make(map[string]bool)
+	err := m.dbTask.Iterate(nil, nil, func(key, value []byte) bool {
+		job := new(cmn.Job)
+		if len(key) != len(job.UUID) {
+			return true
+		}
+		if err := json.Unmarshal(value, job); err != nil {
+			log.Errorf("decode job error, %x, %v", key, err)
+			return true
+		}
+		copy(job.UUID[:], key)
+		for _, k := range indexKeys(job) {
+			expected[string(k)] = true
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	var stale [][]byte
+	err = m.dbIndex.Iterate(nil, nil, func(key, value []byte) bool {
+		k := string(key)
+		if expected[k] {
+			delete(expected, k)
+		} else {
+			stale = append(stale, []byte(k))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	b := m.dbTask.NewBatch()
+	for _, key := range stale {
+		if err := b.Delete(m.dbIndex, key); err != nil {
+			return err
+		}
+		stats.Indexes++
+	}
+	for k := range expected {
+		if err := b.Put(m.dbIndex, []byte(k), nil); err != nil {
+			return err
+		}
+		stats.Missing++
+	}
+	return b.Write()
+}
+
+// reconcileInterrupted fails tasks which were running or retrying when process
+// exited, an interrupted result is stored for each of them.
+func (m *Manager) reconcileInterrupted(stats *ReconcileStats) error {
+	var ids []uint64
+	for _, state := range []cmn.JobState{cmn.JobStateRunning, cmn.JobStateRetrying} {
+		stateIDs, err := m.jobsInState(state)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, stateIDs...)
+	}
+
+	now := time.Now().Unix()
+	for _, id := range ids {
+		job, err := m.getJob(id)
+		if err != nil {
+			log.Errorf("get job error, %d, %v", id, err)
+			continue
+		}
+		r := &cmn.Result{
+			ID:        job.UUID.Int64(),
+			Name:      job.Name,
+			Type:      job.Type,
+			BeginTime: now,
+			EndTime:   now,
+			ErrorMsg:  cmn.ToMsg(cmn.ErrTaskInterrupted),
+		}
+		if err := m.saveResult(r); err != nil {
+			return err
+		}
+		m.enqueueWebhooks(job, r)
+		if err := m.transit(job, cmn.JobStateFailed); err != nil {
+			return err
+		}
+		stats.Interrupted++
+	}
+	return nil
+}
+
+// reconcileScripts removes script files of tasks which do not exist, and writes
+// script files of scheduled or paused tasks which are lost.
+func (m *Manager) reconcileScripts(stats *ReconcileStats) error {
+	files, err := ioutil.ReadDir(m.cmdRoot)
+	if err != nil {
+		return err
+	}
+
+	exists := make(map[int64]bool, len(files))
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		name := f.Name()
+		if strings.HasSuffix(name, ".sh.tmp") {
+			// partial file of an interrupted write
+			os.Remove(filepath.Join(m.cmdRoot, name))
+			continue
+		}
+		if !strings.HasSuffix(name, ".sh") {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, ".sh"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if ok, err := m.dbTask.Has(cmn.EncodeItemID(uint64(id)).Bytes()); err != nil || ok {
+			exists[id] = true
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.cmdRoot, name)); err != nil {
+			log.Errorf("remove script file error, %s: %v", name, err)
+			continue
+		}
+		stats.Scripts++
+	}
+
+	var jobs []*cmn.Job
+	for _, state := range []cmn.JobState{cmn.JobStateScheduled, cmn.JobStatePaused} {
+		ids, err := m.jobsInState(state)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if exists[int64(id)] {
+				continue
+			}
+			job, err := m.getJob(id)
+			if err != nil {
+				log.Errorf("get job error, %d, %v", id, err)
+				continue
+			}
+			if job.Type == cmn.JobTypeFile {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	for _, job := range jobs {
+		if err := m.writeScript(job); err != nil {
+			log.Errorf("write script file error, %d: %v", job.UUID.Int64(), err)
+			continue
+		}
+		stats.Restored++
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+func TestReconcileIndexes(t *testing.T) {
+	m := newTestManager()
+	job := &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1),
+		AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled}
+	putTestJob(t, m, job)
+
+	// indexes of task 2 are left by a crash after its record is deleted.
+	dangling := [][]byte{
+		store.NameIndexKey("gone", 2),
+		store.StateIndexKey(cmn.JobStateScheduled.String(), 2),
+		store.FireIndexKey(1020, 2),
+	}
+	for _, key := range dangling {
+		if err := m.dbIndex.Put(key, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := &ReconcileStats{}
+	if err := m.reconcileIndexes(stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Indexes != len(dangling) {
+		t.Errorf("removed indexes: %d, want %d", stats.Indexes, len(dangling))
+	}
+	for _, key := range dangling {
+		if ok, _ := m.dbIndex.Has(key); ok {
+			t.Errorf("dangling index %x is kept", key)
+		}
+	}
+	for _, key := range [][]byte{
+		store.NameIndexKey("dev", 1),
+		store.StateIndexKey(cmn.JobStateScheduled.String(), 1),
+		store.FireIndexKey(1010, 1),
+	} {
+		if ok, _ := m.dbIndex.Has(key); !ok {
+			t.Errorf("index %x of existing task is removed", key)
+		}
+	}
+}
+
+func TestReconcileInterrupted(t *testing.T) {
+	m := newTestManager()
+	states := []cmn.JobState{cmn.JobStateRunning, cmn.JobStateRetrying, cmn.JobStateScheduled}
+	for i, state := range states {
+		putTestJob(t, m, &cmn.Job{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(uint64(i + 1)),
+			AddTime: 1000, Interval: 10, State: state})
+	}
+
+	stats := &ReconcileStats{}
+	if err := m.reconcileInterrupted(stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Interrupted != 2 {
+		t.Errorf("interrupted: %d, want 2", stats.Interrupted)
+	}
+
+	for i, want := range []cmn.JobState{cmn.JobStateFailed, cmn.JobStateFailed, cmn.JobStateScheduled} {
+		id := uint64(i + 1)
+		job, err := m.getJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.State != want {
+			t.Errorf("task %d: state %s, want %s", id, job.State, want)
+		}
+		r, err := m.Result(int64(id))
+		if want == cmn.JobStateScheduled {
+			if err == nil {
+				t.Errorf("task %d: result of task which is not interrupted: %#v", id, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("task %d: %v", id, err)
+		}
+		if r.ErrorMsg != cmn.ToMsg(cmn.ErrTaskInterrupted) || r.Run != 1 || len(m.history(t, id)) != 1 {
+			t.Errorf("task %d: result %#v", id, r)
+		}
+	}
+	if ids, _ := m.jobsInState(cmn.JobStateRunning); len(ids) != 0 {
+		t.Errorf("running tasks: %v", ids)
+	}
+}
+
+func TestReconcileScripts(t *testing.T) {
+	m := newTestManager()
+	m.cmdRoot = t.TempDir()
+
+	script := []byte("echo ok\n")
+	jobs := []*cmn.Job{
+		{Type: cmn.JobTypeFile, State: cmn.JobStateScheduled, Extra: script}, // script is lost
+		{Type: cmn.JobTypeFile, State: cmn.JobStatePaused, Extra: script},    // script exists
+		{Type: cmn.JobTypeFile, State: cmn.JobStateSucceeded, Extra: script}, // finished, not restored
+		{Type: cmn.JobTypeCmd, State: cmn.JobStateScheduled},                 // no script
+	}
+	for i, job := range jobs {
+		job.Name = "dev"
+		job.UUID = cmn.EncodeItemID(uint64(i + 1))
+		job.AddTime = 1000
+		job.Interval = 10
+		putTestJob(t, m, job)
+	}
+	if err := m.writeScript(jobs[1]); err != nil {
+		t.Fatal(err)
+	}
+	// script of deleted task 9, partial write and unrelated file.
+	for name, data := range map[string]string{"9.sh": "echo 9", "3.sh.tmp": "ec", "notes.txt": "keep"} {
+		if err := ioutil.WriteFile(filepath.Join(m.cmdRoot, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := &ReconcileStats{}
+	if err := m.reconcileScripts(stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scripts != 1 || stats.Restored != 1 {
+		t.Errorf("stats %+v, want 1 orphan removed and 1 restored", stats)
+	}
+
+	for name, kept := range map[string]bool{
+		"1.sh": true, "2.sh": true, "3.sh": false, "4.sh": false,
+		"9.sh": false, "3.sh.tmp": false, "notes.txt": true,
+	} {
+		_, err := os.Stat(filepath.Join(m.cmdRoot, name))
+		if (err == nil) != kept {
+			t.Errorf("%s: exists %v, want %v", name, err == nil, kept)
+		}
+	}
+	data, err := ioutil.ReadFile(m.scriptFile(1))
+	if err != nil || string(data) != string(script) {
+		t.Errorf("restored script %q, %v", data, err)
+	}
+}
+
+func TestReconcileMissingIndexes(t *testing.T) {
+	m := newTestManager()
+	jobs := []*cmn.Job{
+		{Name: "dev", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(1),
+			AddTime: 1000, Interval: 10, State: cmn.JobStateScheduled},
+		{Name: "prod", Type: cmn.JobTypeCmd, UUID: cmn.EncodeItemID(2),
+			AddTime: 1000, Interval: 10, State: cmn.JobStateSucceeded},
+	}
+	for _, job := range jobs {
+		putTestJob(t, m, job)
+	}
+
+	// indexes of task 1 are lost, and task 2 has stale name index.
+	for _, key := range indexKeys(jobs[0]) {
+		if err := m.dbIndex.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.dbIndex.Put(store.NameIndexKey("old", 2), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &ReconcileStats{}
+	if err := m.reconcileIndexes(stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Missing != 3 || stats.Indexes != 1 {
+		t.Errorf("missing: %d, removed: %d, want 3 and 1", stats.Missing, stats.Indexes)
+	}
+	if keys, want := m.indexes(t), wantIndexes(jobs...); !reflect.DeepEqual(keys, want) {
+		t.Fatalf("indexes %q, want %q", keys, want)
+	}
+	if ids, err := m.jobsInState(cmn.JobStateScheduled); err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("scheduled tasks: %v, %v", ids, err)
+	}
+}
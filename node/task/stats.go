@@ -0,0 +1,264 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/metrics"
+)
+
+// Stats is live statistics of scheduler and executions.
+type Stats struct {
+	Tasks    TaskStats             `json:"tasks"`
+	Wheel    WheelStats            `json:"wheel"`
+	Queues   QueueStats            `json:"queues"`
+	Running  []RunningTask         `json:"running"`
+	Names    map[string]*NameStats `json:"names"`    // runs by task name since start
+	Duration DurationStats         `json:"duration"` // durations of runs
+}
+
+// TaskStats is number of tasks in database.
+type TaskStats struct {
+	Total   int            `json:"total"`
+	ByType  map[string]int `json:"by_type"`
+	ByState map[string]int `json:"by_state"`
+}
+
+// WheelStats is occupancy of time wheel, it is updated by every tick.
+type WheelStats struct {
+	Interval    float64 `json:"interval"` // tick interval in seconds
+	Slots       int     `json:"slots"`
+	CurrentSlot int     `json:"current_slot"`
+	Occupied    int     `json:"occupied"` // slots which have tasks
+	Items       int     `json:"items"`    // tasks in wheel
+	Inflight    int     `json:"inflight"` // tasks triggered and not finished
+	Updated     int64   `json:"updated"`
+}
+
+// QueueStats is backlog of executions and webhook deliveries.
+type QueueStats struct {
+	Inflight int `json:"inflight"` // tasks triggered and not finished
+	Due      int `json:"due"`      // tasks triggered by next tick
+	Webhooks int `json:"webhooks"` // pending webhook deliveries
+}
+
+// RunningTask is a task being executed.
+type RunningTask struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Attempt int    `json:"attempt"`
+	Since   int64  `json:"since"` // start time of attempt
+}
+
+// NameStats is outcome of runs of tasks with the same name.
+type NameStats struct {
+	Succeeded uint64 `json:"succeeded"`
+	Failed    uint64 `json:"failed"`
+	LastEnd   int64  `json:"last_end"`
+}
+
+// DurationStats is distribution of run durations in seconds.
+type DurationStats struct {
+	Count int64   `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// snapshotWheel records occupancy of time wheel, m.mu should be held.
+func (m *Manager) snapshotWheel() {
+	ws := WheelStats{
+		Interval:    m.tw.Interval().Seconds(),
+		Slots:       m.tw.MaxSlot(),
+		CurrentSlot: m.tw.CurrentSlot(),
+		Occupied:    m.tw.Occupied(),
+		Items:       m.tw.Len(),
+		Inflight:    len(m.inflight),
+		Updated:     time.Now().Unix(),
+	}
+
+	due := m.tw.Due()
+
+	m.statsMu.Lock()
+	m.wheel = ws
+	m.due = due
+	m.nModule = len(m.modules)
+	m.statsMu.Unlock()
+}
+
+// gauges of scheduler exported by metrics endpoint.
+var gaugeNames = []string{
+	"task/wheel/items",
+	"task/inflight",
+	"task/running",
+	"task/modules",
+	"subscribe/subscribers",
+}
+
+// registerGauges registers gauges of scheduler which read snapshot of
+// statistics, so they never wait for executions holding m.mu.
+func (m *Manager) registerGauges() {
+	values := []func() int64{
+		func() int64 {
+			m.statsMu.Lock()
+			defer m.statsMu.Unlock()
+			return int64(m.wheel.Items)
+		},
+		func() int64 {
+			m.statsMu.Lock()
+			defer m.statsMu.Unlock()
+			return int64(m.wheel.Inflight)
+		},
+		func() int64 {
+			m.statsMu.Lock()
+			defer m.statsMu.Unlock()
+			return int64(len(m.running))
+		},
+		func() int64 {
+			m.statsMu.Lock()
+			defer m.statsMu.Unlock()
+			return int64(m.nModule)
+		},
+		func() int64 {
+			return int64(m.es.Count())
+		},
+	}
+	for i, name := range gaugeNames {
+		gometrics.Unregister(name)
+		gometrics.NewRegisteredFunctionalGauge(name, nil, values[i])
+	}
+}
+
+// unregisterGauges removes gauges of scheduler.
+func unregisterGauges() {
+	for _, name := range gaugeNames {
+		gometrics.Unregister(name)
+	}
+}
+
+// setRunning records attempt of task being executed.
+func (m *Manager) setRunning(job *cmn.Job, attempt int) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	m.running[job.UUID.Int64()] = &RunningTask{
+		ID:      job.UUID.Int64(),
+		Name:    job.Name,
+		Attempt: attempt,
+		Since:   time.Now().Unix(),
+	}
+}
+
+// clearRunning removes task from running executions.
+func (m *Manager) clearRunning(id int64) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	delete(m.running, id)
+}
+
+// recordRun counts outcome of run by task name.
+func (m *Manager) recordRun(name string, r *cmn.Result) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	ns, ok := m.names[name]
+	if !ok {
+		ns = &NameStats{}
+		m.names[name] = ns
+	}
+	if r.Succeeded() {
+		ns.Succeeded++
+	} else {
+		ns.Failed++
+	}
+	ns.LastEnd = r.EndTime
+}
+
+// Stats returns live statistics. It does not wait for executions, so wheel
+// occupancy may be as old as the last tick.
+func (m *Manager) Stats() (*Stats, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Tasks: TaskStats{ByType: make(map[string]int), ByState: make(map[string]int)},
+		Names: make(map[string]*NameStats),
+	}
+
+	err := m.dbTask.Iterate(nil, nil, func(key, value []byte) bool {
+		var job cmn.Job
+		if err := json.Unmarshal(value, &job); err != nil {
+			log.Errorf("json unmarshal job error, %x, %v", key, err)
+			return true
+		}
+		stats.Tasks.Total++
+		stats.Tasks.ByType[job.Type.String()]++
+		stats.Tasks.ByState[job.State.String()]++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = m.dbQueue.Iterate(nil, nil, func(key, value []byte) bool {
+		stats.Queues.Webhooks++
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.statsMu.Lock()
+	stats.Wheel = m.wheel
+	stats.Queues.Inflight = m.wheel.Inflight
+	stats.Queues.Due = m.due
+	stats.Running = make([]RunningTask, 0, len(m.running))
+	for _, r := range m.running {
+		stats.Running = append(stats.Running, *r)
+	}
+	sort.Slice(stats.Running, func(i, j int) bool { return stats.Running[i].ID < stats.Running[j].ID })
+	for name, ns := range m.names {
+		c := *ns
+		stats.Names[name] = &c
+	}
+	m.statsMu.Unlock()
+
+	t := metrics.TaskExecuteTimer.Snapshot()
+	ps := t.Percentiles([]float64{0.5, 0.9, 0.95, 0.99})
+	stats.Duration = DurationStats{
+		Count: t.Count(),
+		Min:   time.Duration(t.Min()).Seconds(),
+		Max:   time.Duration(t.Max()).Seconds(),
+		Mean:  time.Duration(t.Mean()).Seconds(),
+		P50:   time.Duration(ps[0]).Seconds(),
+		P90:   time.Duration(ps[1]).Seconds(),
+		P95:   time.Duration(ps[2]).Seconds(),
+		P99:   time.Duration(ps[3]).Seconds(),
+	}
+	return stats, nil
+}
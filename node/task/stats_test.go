@@ -0,0 +1,62 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"testing"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/store"
+)
+
+func TestStatsQueues(t *testing.T) {
+	m := newTestManager()
+
+	// 1 is due by next tick, 2 is in a later slot, 3 is triggered and running.
+	m.tw.Add(&cmn.Job{UUID: cmn.EncodeItemID(1)})
+	m.tw.Add(&cmn.Job{UUID: cmn.EncodeItemID(2), Interval: 60})
+	m.inflight[3] = struct{}{}
+	m.snapshotWheel()
+
+	b := m.dbTask.NewBatch()
+	for seq := uint64(1); seq <= 3; seq++ {
+		status := DeliveryPending
+		if seq == 3 {
+			status = DeliveryDelivered
+		}
+		if err := m.putDelivery(b, &Delivery{Seq: seq, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := m.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := QueueStats{Inflight: 1, Due: 1, Webhooks: 2}
+	if stats.Queues != want {
+		t.Errorf("queues: %+v, want %+v", stats.Queues, want)
+	}
+	if stats.Wheel.Items != 2 {
+		t.Errorf("items in wheel: %d", stats.Wheel.Items)
+	}
+	if ok, _ := m.dbQueue.Has(store.SeqKey(3)); ok {
+		t.Error("delivered delivery is queued")
+	}
+}
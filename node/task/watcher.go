@@ -18,6 +18,7 @@ package task
 import (
 	"context"
 	"path/filepath"
+	"sync/atomic"
 
 	fsnotify "github.com/rjeczalik/notify"
 	log "github.com/sirupsen/logrus"
@@ -46,6 +47,7 @@ type Watcher struct {
 	root      string
 	chanSize  int
 	chanEvent chan Event
+	running   int32
 }
 
 func (w *Watcher) Start() error {
@@ -57,7 +59,9 @@ func (w *Watcher) Start() error {
 		return err
 	}
 
+	atomic.StoreInt32(&w.running, 1)
 	go func(chanNotify chan fsnotify.EventInfo, chanEvent chan Event) {
+		defer atomic.StoreInt32(&w.running, 0)
 		for {
 			select {
 			case event := <-chanNotify:
@@ -87,6 +91,11 @@ func (w *Watcher) Start() error {
 	return nil
 }
 
+// IsRunning returns watcher is running or not.
+func (w *Watcher) IsRunning() bool {
+	return w != nil && atomic.LoadInt32(&w.running) == 1
+}
+
 func (w *Watcher) Event() <-chan Event {
 	return w.chanEvent
 }
@@ -0,0 +1,442 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	cmn "airman.com/airtask/node/common"
+	"airman.com/airtask/node/metrics"
+	"airman.com/airtask/node/store"
+)
+
+const (
+	DefaultWebhookAttempts   = 10
+	DefaultWebhookMinBackoff = 1 * time.Second
+	DefaultWebhookMaxBackoff = 10 * time.Minute
+	DefaultWebhookTimeout    = 10 * time.Second
+	DefaultWebhookInterval   = 1 * time.Second
+	DefaultWebhookWorkers    = 8                // max endpoints sent to concurrently
+	DefaultWebhookBudget     = 30 * time.Second // max time of a round of deliveries
+	DefaultDeliveryMaxAge    = 7 * 24 * time.Hour
+	DefaultDeliveryLimit     = 100
+	MaxDeliveryLimit         = 1000
+)
+
+// status of webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// headers of webhook request
+const (
+	SignatureHeader = "X-Airtask-Signature" // sha256=hex(hmac-sha256(secret, body))
+	DeliveryHeader  = "X-Airtask-Delivery"  // sequence of delivery
+	EventHeader     = "X-Airtask-Event"
+)
+
+// WebhookOptions is setting of webhook deliveries.
+type WebhookOptions struct {
+	URLs        []string      // webhooks receiving results of all tasks
+	Secret      string        // key of HMAC signature, no signature if it is empty
+	MaxAttempts int           // max attempts of a delivery
+	MinBackoff  time.Duration // backoff after first failed attempt
+	MaxBackoff  time.Duration // max backoff between attempts
+	Timeout     time.Duration // timeout of a request
+}
+
+// Delivery is a POST of result to webhook url, it is kept in persistent queue
+// until it is delivered or attempts are used up.
+type Delivery struct {
+	Seq      uint64          `json:"seq"`
+	TaskID   int64           `json:"task_id"`
+	Run      uint64          `json:"run"`
+	URL      string          `json:"url"`
+	Status   string          `json:"status"`
+	Attempts int             `json:"attempts"`
+	NextTime int64           `json:"next_time,omitempty"` // time of next attempt if it is pending
+	Code     int             `json:"code,omitempty"`      // http status of last attempt
+	Error    string          `json:"error,omitempty"`     // error of last attempt
+	Created  int64           `json:"created"`
+	Updated  int64           `json:"updated"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// DeliveryQuery is condition of listing deliveries, zero value of field means no filter.
+type DeliveryQuery struct {
+	Start  uint64 // first sequence of page, inclusive
+	Limit  int    // max number of deliveries
+	TaskID int64  // uuid of task
+	Status string // status of delivery
+}
+
+// DeliveryPage is a page of deliveries.
+type DeliveryPage struct {
+	Deliveries []Delivery `json:"deliveries"`
+	Next       uint64     `json:"next"` // first sequence of next page, 0 if no more deliveries
+}
+
+// validateWebhooks checks webhook urls are absolute http urls.
+func validateWebhooks(urls []string) error {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cmn.ErrInvalidWebhook
+		}
+	}
+	return nil
+}
+
+// sign returns signature of body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueWebhooks queues deliveries of result to webhooks of job and global
+// webhooks. Job is nil if it is not found.
+func (m *Manager) enqueueWebhooks(job *cmn.Job, r *cmn.Result) {
+	urls := m.opts.Webhook.URLs
+	if job != nil && len(job.Webhooks) > 0 {
+		urls = append(append([]string(nil), urls...), job.Webhooks...)
+	}
+	if len(urls) == 0 || m.dbWebhook == nil {
+		return
+	}
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		log.Errorf("json marshal result error, %#v, %v", r, err)
+		return
+	}
+
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+
+	now := time.Now().Unix()
+	seq := m.hookSeq
+	b := m.dbWebhook.NewBatch()
+	for _, u := range urls {
+		seq++
+		d := &Delivery{
+			Seq:      seq,
+			TaskID:   r.ID,
+			Run:      r.Run,
+			URL:      u,
+			Status:   DeliveryPending,
+			NextTime: now,
+			Created:  now,
+			Updated:  now,
+			Payload:  payload,
+		}
+		if err := m.putDelivery(b, d); err != nil {
+			log.Errorf("db put delivery error, %d, %v", seq, err)
+			return
+		}
+	}
+	if err := b.Put(m.dbMeta, store.WebhookSeqKey, store.SeqKey(seq)); err != nil {
+		log.Errorf("db put delivery sequence error, %d, %v", seq, err)
+		return
+	}
+	if err := b.Write(); err != nil {
+		log.Errorf("write deliveries error, %d, %v", seq, err)
+		return
+	}
+	m.hookSeq = seq
+
+	select {
+	case m.hookWake <- struct{}{}:
+	default:
+	}
+}
+
+// putDelivery writes delivery, pending delivery is in queue.
+func (m *Manager) putDelivery(b *store.Batch, d *Delivery) error {
+	deliveryBytes, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	key := store.SeqKey(d.Seq)
+	if err := b.Put(m.dbWebhook, key, deliveryBytes); err != nil {
+		return err
+	}
+	if d.Status == DeliveryPending {
+		return b.Put(m.dbQueue, key, nil)
+	}
+	return b.Delete(m.dbQueue, key)
+}
+
+// getDelivery returns delivery by sequence.
+func (m *Manager) getDelivery(seq uint64) (*Delivery, error) {
+	value, err := m.dbWebhook.Get(store.SeqKey(seq))
+	if err != nil {
+		return nil, err
+	}
+	d := new(Delivery)
+	if err := json.Unmarshal(value, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// webhookLoop sends pending deliveries when they are queued and periodically
+// for retries. Deliveries left by last run are sent after start.
+func (m *Manager) webhookLoop() {
+	ticker := time.NewTicker(DefaultWebhookInterval)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: m.opts.Webhook.Timeout}
+	for {
+		m.deliverWebhooks(client)
+
+		select {
+		case <-ticker.C:
+		case <-m.hookWake:
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverWebhooks sends deliveries whose next attempt is due. Deliveries of an
+// endpoint are sent in order, and endpoints are sent to concurrently by at most
+// DefaultWebhookWorkers workers, so a slow endpoint doesn't hold up the others.
+// Deliveries not sent within DefaultWebhookBudget are left for next round.
+func (m *Manager) deliverWebhooks(client *http.Client) {
+	now := time.Now().Unix()
+
+	var due []*Delivery
+	err := m.dbQueue.Iterate(nil, nil, func(key, value []byte) bool {
+		d, err := m.getDelivery(binary.BigEndian.Uint64(key))
+		if err != nil {
+			log.Errorf("get delivery error, %x, %v", key, err)
+			return true
+		}
+		if d.NextTime <= now {
+			due = append(due, d)
+		}
+		return len(due) < MaxDeliveryLimit
+	})
+	if err != nil {
+		log.Errorf("iterate webhook queue error, %v", err)
+		return
+	}
+
+	var urls []string
+	endpoints := make(map[string][]*Delivery)
+	for _, d := range due {
+		if _, ok := endpoints[d.URL]; !ok {
+			urls = append(urls, d.URL)
+		}
+		endpoints[d.URL] = append(endpoints[d.URL], d)
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, DefaultWebhookBudget)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, DefaultWebhookWorkers)
+	for _, u := range urls {
+		select {
+		case workers <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(ds []*Delivery) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			for _, d := range ds {
+				if ctx.Err() != nil {
+					return
+				}
+				m.attempt(ctx, client, d)
+			}
+		}(endpoints[u])
+	}
+	wg.Wait()
+}
+
+// attempt sends delivery once, delivery is failed if attempts are used up and
+// it is retried after backoff otherwise. Delivery is not changed if ctx is done
+// during attempt, it is not counted as an attempt.
+func (m *Manager) attempt(ctx context.Context, client *http.Client, d *Delivery) {
+	code, err := m.post(ctx, client, d)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	opts := m.opts.Webhook
+	now := time.Now()
+	d.Attempts++
+	d.Code = code
+	d.Error = ""
+	d.Updated = now.Unix()
+	switch {
+	case err == nil:
+		d.Status = DeliveryDelivered
+		d.NextTime = 0
+		metrics.WebhookDeliveredCounter.Inc(1)
+	case d.Attempts >= opts.MaxAttempts:
+		d.Status = DeliveryFailed
+		d.NextTime = 0
+		d.Error = err.Error()
+		metrics.WebhookFailedCounter.Inc(1)
+		log.Warnf("webhook delivery failed, %d, %s, %v", d.Seq, d.URL, err)
+	default:
+		d.Error = err.Error()
+		d.NextTime = now.Add(webhookBackoff(opts, d.Attempts)).Unix()
+		metrics.WebhookRetryCounter.Inc(1)
+	}
+
+	b := m.dbWebhook.NewBatch()
+	if err := m.putDelivery(b, d); err != nil {
+		log.Errorf("db put delivery error, %d, %v", d.Seq, err)
+		return
+	}
+	if err := b.Write(); err != nil {
+		log.Errorf("write delivery error, %d, %v", d.Seq, err)
+	}
+}
+
+// post sends payload of delivery, response of 2xx is success.
+func (m *Manager) post(ctx context.Context, client *http.Client, d *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, cmn.EventResult)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(d.Seq, 10))
+	if secret := m.opts.Webhook.Secret; secret != "" {
+		req.Header.Set(SignatureHeader, sign(secret, d.Payload))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff returns exponential backoff with jitter after the given attempts.
+func webhookBackoff(opts WebhookOptions, attempts int) time.Duration {
+	backoff := opts.MinBackoff
+	for i := 1; i < attempts && backoff < opts.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > opts.MaxBackoff {
+		backoff = opts.MaxBackoff
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// Deliveries lists webhook deliveries ordered by sequence.
+func (m *Manager) Deliveries(q *DeliveryQuery) (*DeliveryPage, error) {
+	if err := m.checkRunning(); err != nil {
+		return nil, err
+	}
+
+	if q == nil {
+		q = &DeliveryQuery{}
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultDeliveryLimit
+	} else if limit > MaxDeliveryLimit {
+		limit = MaxDeliveryLimit
+	}
+
+	var start []byte
+	if q.Start > 0 {
+		start = store.SeqKey(q.Start)
+	}
+
+	page := &DeliveryPage{Deliveries: make([]Delivery, 0, limit)}
+	err := m.dbWebhook.Iterate(nil, start, func(key, value []byte) bool {
+		var d Delivery
+		if err := json.Unmarshal(value, &d); err != nil {
+			log.Errorf("json unmarshal delivery error, %x, %v", key, err)
+			return true
+		}
+		if (q.TaskID != 0 && d.TaskID != q.TaskID) || (q.Status != "" && d.Status != q.Status) {
+			return true
+		}
+		if len(page.Deliveries) == limit {
+			page.Next = d.Seq
+			return false
+		}
+		page.Deliveries = append(page.Deliveries, d)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// compactDeliveries deletes delivered and failed deliveries updated before deadline.
+func (m *Manager) compactDeliveries(deadline int64, stats *CompactStats) error {
+	var keys [][]byte
+	err := m.dbWebhook.Iterate(nil, nil, func(key, value []byte) bool {
+		var d Delivery
+		if err := json.Unmarshal(value, &d); err != nil {
+			return true
+		}
+		if d.Status != DeliveryPending && d.Updated < deadline {
+			keys = append(keys, key)
+			stats.Bytes += int64(len(key) + len(value))
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := deleteKeys(m.dbWebhook, keys); err != nil {
+		return err
+	}
+	stats.Deliveries += len(keys)
+	return nil
+}
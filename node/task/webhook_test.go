@@ -0,0 +1,190 @@
+// Copyright 2018 The huayulei_2003@hotmail.com Authors
+// This file is part of the airfk library.
+//
+// The airfk library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The airfk library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the airfk library. If not, see <http://www.gnu.org/licenses/>.
+package task
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cmn "airman.com/airtask/node/common"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	var (
+		bodies     [][]byte
+		signatures []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, body)
+		signatures = append(signatures, r.Header.Get(SignatureHeader))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	m := newTestManager()
+	m.opts.Webhook.Secret = "secret"
+	m.opts.Webhook.MinBackoff = time.Millisecond
+	m.opts.Webhook.MaxBackoff = time.Millisecond
+
+	job := &cmn.Job{Name: "dev", UUID: cmn.EncodeItemID(1), Webhooks: []string{srv.URL}}
+	m.enqueueWebhooks(job, &cmn.Result{ID: 1, Run: 1, ErrorMsg: cmn.ToMsg(nil)})
+
+	// first attempt fails and is retried after backoff.
+	m.deliverWebhooks(srv.Client())
+	page, err := m.Deliveries(&DeliveryQuery{TaskID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deliveries) != 1 {
+		t.Fatalf("deliveries: %d, want 1", len(page.Deliveries))
+	}
+	if d := page.Deliveries[0]; d.Status != DeliveryPending || d.Attempts != 1 || d.Code != http.StatusInternalServerError {
+		t.Fatalf("delivery after failure: %+v", d)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	m.deliverWebhooks(srv.Client())
+	page, err = m.Deliveries(&DeliveryQuery{Status: DeliveryDelivered})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deliveries) != 1 || page.Deliveries[0].Attempts != 2 {
+		t.Fatalf("delivered: %+v", page.Deliveries)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("requests: %d, want 2", len(bodies))
+	}
+	if want := sign("secret", bodies[1]); signatures[1] != want {
+		t.Fatalf("signature: %s, want %s", signatures[1], want)
+	}
+
+	// delivered delivery is not sent again.
+	m.deliverWebhooks(srv.Client())
+	if len(bodies) != 2 {
+		t.Fatalf("requests after delivered: %d, want 2", len(bodies))
+	}
+}
+
+func TestWebhookFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	m := newTestManager()
+	m.opts.Webhook.URLs = []string{srv.URL}
+	m.opts.Webhook.MaxAttempts = 1
+
+	m.enqueueWebhooks(nil, &cmn.Result{ID: 2})
+	m.deliverWebhooks(srv.Client())
+
+	page, err := m.Deliveries(&DeliveryQuery{Status: DeliveryFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deliveries) != 1 || page.Deliveries[0].Error == "" {
+		t.Fatalf("failed: %+v", page.Deliveries)
+	}
+}
+
+func TestWebhookConcurrent(t *testing.T) {
+	fastDone := make(chan struct{})
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(fastDone)
+	}))
+	defer fast.Close()
+	// slow endpoint answers after fast endpoint is sent to, it fails if
+	// endpoints are sent to one by one.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-fastDone:
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer slow.Close()
+
+	m := newTestManager()
+	m.opts.Webhook.URLs = []string{slow.URL, fast.URL}
+
+	m.enqueueWebhooks(nil, &cmn.Result{ID: 3})
+	m.deliverWebhooks(http.DefaultClient)
+
+	page, err := m.Deliveries(&DeliveryQuery{Status: DeliveryDelivered})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deliveries) != 2 {
+		t.Fatalf("delivered: %+v", page.Deliveries)
+	}
+}
+
+func TestWebhookStopped(t *testing.T) {
+	received, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	m := newTestManager()
+	m.opts.Webhook.URLs = []string{srv.URL}
+
+	m.enqueueWebhooks(nil, &cmn.Result{ID: 4})
+	done := make(chan struct{})
+	go func() {
+		m.deliverWebhooks(srv.Client())
+		close(done)
+	}()
+	<-received
+	m.cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery is not stopped")
+	}
+
+	// delivery interrupted by stop is not counted as an attempt.
+	page, err := m.Deliveries(&DeliveryQuery{TaskID: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Deliveries) != 1 {
+		t.Fatalf("deliveries: %d, want 1", len(page.Deliveries))
+	}
+	if d := page.Deliveries[0]; d.Status != DeliveryPending || d.Attempts != 0 || d.Error != "" {
+		t.Fatalf("delivery after stop: %+v", d)
+	}
+}
+
+func TestValidateWebhooks(t *testing.T) {
+	if err := validateWebhooks([]string{"https://example.com/hook"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"example.com/hook", "ftp://example.com", "http://"} {
+		if err := validateWebhooks([]string{u}); err != cmn.ErrInvalidWebhook {
+			t.Fatalf("%s: %v, want %v", u, err, cmn.ErrInvalidWebhook)
+		}
+	}
+}
@@ -61,6 +61,38 @@ func (tw *TimeWheel) MaxSlot() int {
 	return tw.maxSlot
 }
 
+// CurrentSlot returns slot which is triggered next.
+func (tw *TimeWheel) CurrentSlot() int {
+	return tw.currentSlot
+}
+
+// Len returns number of items in wheel.
+func (tw *TimeWheel) Len() int {
+	return len(tw.mapItems)
+}
+
+// Occupied returns number of slots which have items.
+func (tw *TimeWheel) Occupied() int {
+	n := 0
+	for _, l := range tw.slots {
+		if l.Len() > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Due returns number of items which are triggered by next trigger.
+func (tw *TimeWheel) Due() int {
+	n := 0
+	for e := tw.slots[tw.currentSlot].Front(); e != nil; e = e.Next() {
+		if e.Value.(*TimeItem).circle == 0 {
+			n++
+		}
+	}
+	return n
+}
+
 func calcSlotAndCircle(d time.Duration, interval time.Duration, currentSlot, maxSlot int) (int, int) {
 	delaySeconds := int(d.Seconds())
 	intervalSeconds := int(interval.Seconds())